@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// serveAPI starts the JSON control API on addr in the background. With a
+// token set, requests must carry it as "Authorization: Bearer <token>".
+func (m *Miner) serveAPI(addr, token string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("api: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, m.Status())
+	})
+	// xmrig's summary, for farm-management tools
+	mux.HandleFunc("GET /1/summary", m.handleXmrigSummary)
+	mux.HandleFunc("GET /2/summary", m.handleXmrigSummary)
+	mux.HandleFunc("POST /pause", func(w http.ResponseWriter, r *http.Request) {
+		m.Pause()
+		writeJSON(w, http.StatusOK, m.Status())
+	})
+	mux.HandleFunc("POST /resume", func(w http.ResponseWriter, r *http.Request) {
+		m.Resume()
+		writeJSON(w, http.StatusOK, m.Status())
+	})
+	mux.HandleFunc("POST /threads", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Threads int `json:"threads"`
+		}
+		if err := readJSON(r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := m.SetThreads(req.Threads); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, m.Status())
+	})
+	mux.HandleFunc("POST /pool", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Addr string `json:"addr"`
+			User string `json:"user"`
+			Pass string `json:"pass"`
+		}
+		if err := readJSON(r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if req.Addr == "" || req.User == "" {
+			writeError(w, http.StatusBadRequest, errors.New("addr and user are required"))
+			return
+		}
+		if req.Pass == "" {
+			req.Pass = "x"
+		}
+		if err := m.SwitchPool(req.Addr, req.User, req.Pass); err != nil {
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, m.Status())
+	})
+
+	m.api = &http.Server{Handler: requireToken(token, mux), ReadHeaderTimeout: 10 * time.Second}
+	m.logger.Info("Serving control API", zap.String("addr", ln.Addr().String()), zap.Bool("token", token != ""))
+	go func() {
+		if err := m.api.Serve(ln); err != nil && err != http.ErrServerClosed {
+			m.logger.Error("Control API failed", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+// requireToken rejects requests without the bearer token, if one is set
+func requireToken(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("missing or bad token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func readJSON(r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("bad request body: %w", err)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
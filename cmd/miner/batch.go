@@ -0,0 +1,43 @@
+package main
+
+import "time"
+
+// maxBatch caps the nonces of one Solve however fast graphs go
+const maxBatch = 1 << 16
+
+// batcher sizes a worker's Solve calls to take about target wall-clock
+// time, from a running average of the measured time per graph. Short calls
+// keep job switches and cancellation prompt; long ones amortise the
+// per-call overhead.
+type batcher struct {
+	target   time.Duration
+	perGraph time.Duration // smoothed; zero until the first measurement
+}
+
+func newBatcher(target time.Duration) *batcher {
+	return &batcher{target: target}
+}
+
+// size is the number of nonces to hand the next Solve. The first call
+// probes with a single graph.
+func (b *batcher) size() uint32 {
+	if b.perGraph <= 0 || b.target <= 0 {
+		return 1
+	}
+	return uint32(min(max(int64(b.target/b.perGraph), 1), maxBatch))
+}
+
+// observe records a Solve that searched graphs graphs in elapsed. Calls
+// that searched none (cancelled before the first graph) say nothing about
+// graph time.
+func (b *batcher) observe(elapsed time.Duration, graphs int) {
+	if graphs <= 0 {
+		return
+	}
+	g := elapsed / time.Duration(graphs)
+	if b.perGraph == 0 {
+		b.perGraph = g
+		return
+	}
+	b.perGraph += (g - b.perGraph) / 4
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBatcher(t *testing.T) {
+	type solve struct {
+		elapsed time.Duration
+		graphs  int
+	}
+	for _, c := range []struct {
+		name   string
+		target time.Duration
+		solves []solve
+		want   uint32
+	}{
+		{"probes with one graph", 250 * time.Millisecond, nil, 1},
+		{"sized from the first solve", 250 * time.Millisecond, []solve{{10 * time.Millisecond, 1}}, 25},
+		{"per graph, not per call", 250 * time.Millisecond, []solve{{100 * time.Millisecond, 4}}, 10},
+		{"cancelled solves say nothing", 250 * time.Millisecond, []solve{{10 * time.Millisecond, 1}, {time.Second, 0}}, 25},
+		// 10ms, then 50ms moves the average a quarter of the way: 20ms
+		{"smoothed", 200 * time.Millisecond, []solve{{10 * time.Millisecond, 1}, {50 * time.Millisecond, 1}}, 10},
+		{"graphs slower than the target", 250 * time.Millisecond, []solve{{time.Second, 1}}, 1},
+		{"capped", time.Second, []solve{{time.Microsecond, 1}}, maxBatch},
+		{"no target", 0, []solve{{10 * time.Millisecond, 1}}, 1},
+	} {
+		b := newBatcher(c.target)
+		for _, s := range c.solves {
+			b.observe(s.elapsed, s.graphs)
+		}
+		if got := b.size(); got != c.want {
+			t.Errorf("%s: size = %d, want %d", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	pkgsolver "github.com/nitrogen/go-miner/pkg/solver"
+)
+
+// benchResult is one backend/thread configuration of `miner bench`
+type benchResult struct {
+	Backend           string             `json:"backend"`
+	Threads           int                `json:"threads"`
+	Instances         int                `json:"instances"`
+	Topology          string             `json:"topology"`
+	Params            string             `json:"params"`
+	Graphs            int                `json:"graphs"`
+	Seconds           float64            `json:"seconds"`
+	GraphsPerSec      float64            `json:"graphs_per_sec"`
+	SolutionsPerGraph float64            `json:"solutions_per_graph"`
+	SolverMemBytes    uint64             `json:"solver_mem_bytes"`
+	PeakRSSBytes      uint64             `json:"peak_rss_bytes,omitempty"`
+	HugePageBytes     uint64             `json:"huge_page_bytes"`
+	PhaseMsPerGraph   map[string]float64 `json:"phase_ms_per_graph,omitempty"`
+	Error             string             `json:"error,omitempty"`
+}
+
+// runBench implements `miner bench`: solve a fixed set of graphs with each
+// backend/thread configuration and report throughput, memory and phases
+func runBench(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	var (
+		solvers   = fs.String("solver", "all", "Comma-separated solver backends, or all ("+strings.Join(pkgsolver.Backends(), ", ")+")")
+		threads   = fs.String("t", "1", "Comma-separated thread counts to try")
+		topos     = fs.String("topology", "", "Comma-separated SxT topologies to try instead of -t (e.g. 8x1,2x4,1x8)")
+		graphs    = fs.Int("graphs", 8, "Graphs to solve per configuration")
+		edgeBits  = fs.Int("edgebits", pkgsolver.DefaultParams.EdgeBits, "Cuckoo graph size (log2 edges)")
+		proofSize = fs.Int("proofsize", pkgsolver.DefaultParams.ProofSize, "Cuckoo cycle length")
+		huge      = fs.String("hugepages", "off", "Back solver memory with huge pages: off, thp or hugetlb")
+		jsonOut   = fs.String("json", "", "Also write results as JSON to this file (- for stdout)")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s bench [flags]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *graphs < 1 {
+		return fmt.Errorf("bench: -graphs must be at least 1")
+	}
+	backends := pkgsolver.Backends()
+	if *solvers != "all" {
+		backends = strings.Split(*solvers, ",")
+	}
+	// A plain thread count t is laid out as the miner's default would: one
+	// t-thread solver for multi-threaded backends, else t single-threaded ones
+	type layout struct {
+		threads int
+		topo    *Topology
+	}
+	var layouts []layout
+	if *topos != "" {
+		for _, f := range strings.Split(*topos, ",") {
+			t, auto, err := parseTopology(f)
+			if err != nil || auto {
+				return fmt.Errorf("bench: bad topology %q", f)
+			}
+			layouts = append(layouts, layout{threads: t.Threads(), topo: &t})
+		}
+	} else {
+		for _, f := range strings.Split(*threads, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(f))
+			if err != nil || n < 1 {
+				return fmt.Errorf("bench: bad thread count %q", f)
+			}
+			layouts = append(layouts, layout{threads: n})
+		}
+	}
+	params := pkgsolver.Params{EdgeBits: *edgeBits, ProofSize: *proofSize}
+	if err := params.Validate(); err != nil {
+		return err
+	}
+	hugePages, err := pkgsolver.ParseHugePages(*huge)
+	if err != nil {
+		return err
+	}
+	pkgsolver.SetHugePages(hugePages)
+
+	var results []benchResult
+	for _, name := range backends {
+		for _, l := range layouts {
+			fmt.Fprintf(os.Stderr, "bench: %s, %d threads, %d graphs of %s...\n", name, l.threads, *graphs, params)
+			results = append(results, benchConfig(strings.TrimSpace(name), l.threads, l.topo, params, *graphs))
+		}
+	}
+
+	printBenchTable(os.Stdout, results)
+	if *jsonOut != "" {
+		return writeBenchJSON(*jsonOut, results)
+	}
+	return nil
+}
+
+// benchHeaders returns n fixed 80-byte headers, so runs are comparable
+func benchHeaders(n int) [][]byte {
+	headers := make([][]byte, n)
+	for i := range headers {
+		h := make([]byte, 80)
+		binary.LittleEndian.PutUint32(h[4:], uint32(i))
+		headers[i] = h
+	}
+	return headers
+}
+
+// benchConfig solves graphs headers with the backend laid out as topo, or
+// if topo is nil as the miner would by default: one multi-threaded
+// instance, or one single-threaded instance per thread
+func benchConfig(name string, threads int, topo *Topology, params pkgsolver.Params, graphs int) benchResult {
+	res := benchResult{Backend: name, Threads: threads, Params: params.String(), Graphs: graphs}
+	info, err := pkgsolver.LookupBackend(name)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	instances, perInstance := threads, 1
+	if info.MultiThreaded {
+		instances, perInstance = 1, threads
+	}
+	if topo != nil {
+		instances, perInstance = topo.Solvers, topo.ThreadsPerSolver
+	}
+	res.Instances = instances
+	res.Topology = fmt.Sprintf("%dx%d", instances, perInstance)
+	mem, err := info.Memory(params, perInstance)
+	if err != nil {
+		res.Error = err.Error()
+		return res
+	}
+	res.SolverMemBytes = mem * uint64(instances)
+
+	backends := make([]pkgsolver.Backend, 0, instances)
+	defer func() {
+		for _, b := range backends {
+			b.Close()
+		}
+	}()
+	for i := 0; i < instances; i++ {
+		b, err := info.New(params, perInstance)
+		if err != nil {
+			res.Error = err.Error()
+			return res
+		}
+		backends = append(backends, b)
+	}
+
+	resetPeakRSS()
+	headers := benchHeaders(graphs)
+	next := make(chan []byte, len(headers))
+	for _, h := range headers {
+		next <- h
+	}
+	close(next)
+
+	var (
+		mu        sync.Mutex
+		searched  int
+		solutions int
+		phases    = map[string]time.Duration{}
+		firstErr  error
+		hugeSeen  = map[pkgsolver.Backend]bool{}
+		wg        sync.WaitGroup
+	)
+	start := time.Now()
+	for _, b := range backends {
+		wg.Add(1)
+		go func(b pkgsolver.Backend) {
+			defer wg.Done()
+			for header := range next {
+				err := b.SetHeader(header)
+				var sols []pkgsolver.Solution
+				if err == nil {
+					// The graph is keyed by the header alone: one nonce, one graph
+					sols, err = b.Solve(0, 1)
+				}
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				searched += b.LastGraphs()
+				if hr, ok := b.(pkgsolver.HugePageReporter); ok && !hugeSeen[b] {
+					// Memory is allocated by the first solve, so sample once after it
+					hugeSeen[b] = true
+					res.HugePageBytes += hr.HugePages().Huge()
+				}
+				solutions += len(sols)
+				if pr, ok := b.(pkgsolver.PhaseReporter); ok {
+					for _, ph := range pr.LastPhases() {
+						phases[ph.Name] += ph.Duration
+					}
+				}
+				mu.Unlock()
+			}
+		}(b)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	if firstErr != nil {
+		res.Error = firstErr.Error()
+	}
+	res.Seconds = elapsed.Seconds()
+	// Only graphs the backend searched to the end count towards the rate
+	res.GraphsPerSec = float64(searched) / elapsed.Seconds()
+	res.SolutionsPerGraph = float64(solutions) / float64(graphs)
+	res.PeakRSSBytes = peakRSS()
+	if len(phases) > 0 {
+		res.PhaseMsPerGraph = make(map[string]float64, len(phases))
+		for name, d := range phases {
+			res.PhaseMsPerGraph[name] = float64(d) / float64(time.Millisecond) / float64(graphs)
+		}
+	}
+	return res
+}
+
+func printBenchTable(w io.Writer, results []benchResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "BACKEND\tTOPOLOGY\tPARAMS\tGRAPHS\tGRAPHS/S\tSOLS/GRAPH\tSOLVER MEM\tPEAK RSS\tHUGE PAGES\tPHASES (ms/graph)")
+	for _, r := range results {
+		if r.Error != "" && r.Seconds == 0 {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t-\t-\t-\t-\t-\t-\terror: %s\n", r.Backend, r.Topology, r.Params, r.Error)
+			continue
+		}
+		phases := make([]string, 0, len(r.PhaseMsPerGraph))
+		for _, name := range []string{"setup", "trim", "search", "trim+search"} {
+			if ms, ok := r.PhaseMsPerGraph[name]; ok {
+				phases = append(phases, fmt.Sprintf("%s %.1f", name, ms))
+			}
+		}
+		if r.Error != "" {
+			phases = append(phases, "error: "+r.Error)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%.3f\t%.3f\t%s\t%s\t%s\t%s\n",
+			r.Backend, r.Topology, r.Params, r.Graphs, r.GraphsPerSec, r.SolutionsPerGraph,
+			formatBytes(r.SolverMemBytes), formatBytes(r.PeakRSSBytes), formatBytes(r.HugePageBytes),
+			strings.Join(phases, ", "))
+	}
+	tw.Flush()
+}
+
+func writeBenchJSON(path string, results []benchResult) error {
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// formatBytes renders n in binary units; 0 means unknown
+func formatBytes(n uint64) string {
+	if n == 0 {
+		return "n/a"
+	}
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nitrogen/go-miner/pkg/stratum"
+	"go.uber.org/zap"
+)
+
+// userAgent identifies the miner to farm-management tools
+const userAgent = "go-miner/1.0"
+
+// Farm-management tools poll miners through the APIs of the popular ones.
+// Both report graphs per second where those miners report hashes.
+
+// xmrigSummary is the subset of xmrig's /2/summary that monitoring reads
+type xmrigSummary struct {
+	ID         string          `json:"id"`
+	WorkerID   string          `json:"worker_id"`
+	Uptime     int64           `json:"uptime"`
+	Restricted bool            `json:"restricted"`
+	Version    string          `json:"version"`
+	Kind       string          `json:"kind"`
+	UA         string          `json:"ua"`
+	Algo       string          `json:"algo"`
+	Paused     bool            `json:"paused"`
+	Hashrate   xmrigHashrate   `json:"hashrate"`
+	Results    xmrigResults    `json:"results"`
+	Connection xmrigConnection `json:"connection"`
+}
+
+// xmrigHashrate holds rates over 10s, 60s and 15m, the total and per thread
+type xmrigHashrate struct {
+	Total   []float64   `json:"total"`
+	Highest float64     `json:"highest"`
+	Threads [][]float64 `json:"threads"`
+}
+
+type xmrigResults struct {
+	DiffCurrent float64  `json:"diff_current"`
+	SharesGood  uint64   `json:"shares_good"`
+	SharesTotal uint64   `json:"shares_total"`
+	AvgTime     int64    `json:"avg_time"` // seconds between accepted shares
+	HashesTotal uint64   `json:"hashes_total"`
+	ErrorLog    []string `json:"error_log"`
+}
+
+type xmrigConnection struct {
+	Pool     string   `json:"pool"`
+	Uptime   int64    `json:"uptime"`
+	Ping     int64    `json:"ping"` // ms, mean RPC round trip
+	Failures uint64   `json:"failures"`
+	Accepted uint64   `json:"accepted"`
+	Rejected uint64   `json:"rejected"`
+	Algo     string   `json:"algo"`
+	Diff     float64  `json:"diff"`
+	ErrorLog []string `json:"error_log"`
+}
+
+// xmrigSummary fills in the xmrig summary from the miner's stats
+func (m *Miner) xmrigSummary() xmrigSummary {
+	now := time.Now()
+	c := m.client.Load()
+	algo := "cuckoo" + strconv.Itoa(m.cfg.Params.EdgeBits)
+	uptime := now.Sub(m.stats.StartTime)
+	accepted, rejected := m.stats.Shares.Total(), m.stats.SharesRejected.Load()
+
+	s := xmrigSummary{
+		ID:         c.Username(),
+		WorkerID:   c.Username(),
+		Uptime:     int64(uptime.Seconds()),
+		Restricted: true,
+		Version:    strings.TrimPrefix(userAgent, "go-miner/"),
+		Kind:       "miner",
+		UA:         userAgent,
+		Algo:       algo,
+		Paused:     m.paused.Load(),
+		Hashrate: xmrigHashrate{
+			Total: []float64{m.stats.Graphs.LastRate(), m.stats.Graphs.Rates()[0], m.stats.Graphs.Rates()[2]},
+		},
+		Results: xmrigResults{
+			DiffCurrent: c.GetDifficulty(),
+			SharesGood:  accepted,
+			SharesTotal: accepted + rejected,
+			HashesTotal: m.stats.Graphs.Total(),
+			ErrorLog:    []string{},
+		},
+		Connection: xmrigConnection{
+			Pool:     c.Addr(),
+			Ping:     meanRPCMillis(c),
+			Failures: c.Reconnects(),
+			Accepted: accepted,
+			Rejected: rejected,
+			Algo:     algo,
+			Diff:     c.GetDifficulty(),
+			ErrorLog: []string{},
+		},
+	}
+	s.Hashrate.Highest = slices.Max(s.Hashrate.Total)
+	if accepted > 0 {
+		s.Results.AvgTime = int64(uptime.Seconds()) / int64(accepted)
+	}
+	if t := c.ConnectedSince(); !t.IsZero() && c.Connected() {
+		s.Connection.Uptime = int64(now.Sub(t).Seconds())
+	}
+
+	m.solversMu.Lock()
+	for _, ws := range m.stats.Workers {
+		r := ws.Graphs.Rates()
+		s.Hashrate.Threads = append(s.Hashrate.Threads, []float64{ws.Graphs.LastRate(), r[0], r[2]})
+	}
+	m.solversMu.Unlock()
+	return s
+}
+
+// meanRPCMillis is the mean round trip of all answered pool calls
+func meanRPCMillis(c *stratum.Client) int64 {
+	var sum float64
+	var n uint64
+	for _, h := range c.RPCLatency() {
+		sum += h.Sum
+		n += h.Count
+	}
+	if n == 0 {
+		return 0
+	}
+	return int64(math.Round(sum / float64(n) * 1e3))
+}
+
+// handleXmrigSummary serves xmrig's /1/summary and /2/summary
+func (m *Miner) handleXmrigSummary(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, m.xmrigSummary())
+}
+
+// claymoreStat is the result of Claymore's miner_getstat1: an array of
+// ";"-separated strings. The rate fields, nominally KH/s, carry thousandths
+// of graphs per second, so tools that show them in MH/s show graphs/s.
+func (m *Miner) claymoreStat() []string {
+	c := m.client.Load()
+	milli := func(r float64) string { return strconv.FormatInt(int64(math.Round(r*1e3)), 10) }
+	rejected := m.stats.SharesRejected.Load()
+
+	m.solversMu.Lock()
+	perWorker := make([]string, len(m.stats.Workers))
+	for i, ws := range m.stats.Workers {
+		perWorker[i] = milli(ws.Graphs.Rates()[0])
+	}
+	offs := strings.TrimSuffix(strings.Repeat("off;", len(perWorker)), ";")
+	m.solversMu.Unlock()
+	temps := "" // one temperature;fan pair, for the CPU
+	if temp, ok := m.cpuTemp(); ok {
+		temps = fmt.Sprintf("%.0f;0", temp)
+	}
+
+	return []string{
+		userAgent + " - CUCKOO" + strconv.Itoa(m.cfg.Params.EdgeBits),
+		strconv.Itoa(int(time.Since(m.stats.StartTime).Minutes())),
+		fmt.Sprintf("%s;%d;%d", milli(m.stats.Graphs.Rates()[0]), m.stats.Shares.Total(), rejected),
+		strings.Join(perWorker, ";"),
+		"0;0;0", // no dual mining
+		offs,
+		temps,
+		c.Addr(),
+		fmt.Sprintf("%d;%d;0;0", rejected, m.stats.PoolSwitches.Load()),
+	}
+}
+
+// serveClaymore starts Claymore's TCP JSON-RPC API on addr in the
+// background. It answers miner_getstat1 only, one request per connection.
+func (m *Miner) serveClaymore(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("claymore api: %w", err)
+	}
+	m.claymore = ln
+	m.logger.Info("Serving Claymore API", zap.String("addr", ln.Addr().String()))
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go m.handleClaymore(conn)
+		}
+	}()
+	return nil
+}
+
+func (m *Miner) handleClaymore(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	var req struct {
+		ID     any    `json:"id"`
+		Method string `json:"method"`
+	}
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return
+	}
+	resp := map[string]any{"id": req.ID, "jsonrpc": "2.0"}
+	if req.Method == "miner_getstat1" {
+		resp["result"] = m.claymoreStat()
+		resp["error"] = nil
+	} else {
+		resp["result"] = nil
+		resp["error"] = "unsupported method"
+	}
+	data, _ := json.Marshal(resp)
+	conn.Write(append(data, '\n'))
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"time"
+
+	"github.com/nitrogen/go-miner/pkg/events"
+	"github.com/nitrogen/go-miner/pkg/stats"
+	"github.com/nitrogen/go-miner/pkg/sysinfo"
+	"go.uber.org/zap"
+)
+
+// Status is a snapshot of the miner for the control API
+type Status struct {
+	Pool       string  `json:"pool"`
+	User       string  `json:"user"`
+	Connected  bool    `json:"connected"`
+	Paused     bool    `json:"paused"`
+	Job        string  `json:"job,omitempty"`
+	Difficulty float64 `json:"difficulty"`
+	Uptime     float64 `json:"uptime_seconds"`
+	Backend    string  `json:"backend"`
+	Topology   string  `json:"topology"`
+	Threads    int     `json:"threads"`
+	// PausedBy and ThreadCaps say who holds mining back: the user, the
+	// idle scheduler or thermal throttling
+	PausedBy   []string       `json:"paused_by,omitempty"`
+	ThreadCaps map[string]int `json:"thread_caps,omitempty"`
+	// CPUTemp is in degrees Celsius, with thermal throttling on and the
+	// sensor read
+	CPUTemp      *float64 `json:"cpu_temp_c,omitempty"`
+	ThermalLevel int      `json:"thermal_level,omitempty"`
+	// GraphsPerSec are the 1m, 5m and 15m averages
+	GraphsPerSec []float64      `json:"graphs_per_sec"`
+	Graphs       uint64         `json:"graphs"`
+	Solutions    uint64         `json:"solutions"`
+	Shares       ShareStatus    `json:"shares"`
+	Workers      []WorkerStatus `json:"workers"`
+}
+
+// ShareStatus counts submitted shares
+type ShareStatus struct {
+	Accepted      uint64            `json:"accepted"`
+	Rejected      uint64            `json:"rejected"`
+	RejectReasons map[string]uint64 `json:"reject_reasons,omitempty"`
+}
+
+// WorkerStatus is one worker's share of the work
+type WorkerStatus struct {
+	ID           int     `json:"id"`
+	Graphs       uint64  `json:"graphs"`
+	GraphsPerSec float64 `json:"graphs_per_sec"` // 1m average
+	Placement    string  `json:"placement,omitempty"`
+}
+
+// Status returns the miner's current state
+func (m *Miner) Status() Status {
+	c := m.client.Load()
+	st := Status{
+		Pool:         c.Addr(),
+		User:         c.Username(),
+		Connected:    c.Connected(),
+		Paused:       m.paused.Load(),
+		Difficulty:   c.GetDifficulty(),
+		Uptime:       time.Since(m.stats.StartTime).Seconds(),
+		Backend:      m.cfg.Backend,
+		GraphsPerSec: rates(m.stats.Graphs.Rates()),
+		Graphs:       m.stats.Graphs.Total(),
+		Solutions:    m.stats.Cycles.Total(),
+		Shares: ShareStatus{
+			Accepted:      m.stats.Shares.Total(),
+			Rejected:      m.stats.SharesRejected.Load(),
+			RejectReasons: m.stats.RejectReasons.Snapshot(),
+		},
+	}
+	if temp, ok := m.cpuTemp(); ok {
+		st.CPUTemp = &temp
+	}
+	st.ThermalLevel = int(m.stats.ThermalLevel.Load())
+	m.workMutex.RLock()
+	if m.currentWork != nil {
+		st.Job = m.currentWork.JobID
+	}
+	m.workMutex.RUnlock()
+
+	m.solversMu.Lock()
+	defer m.solversMu.Unlock()
+	st.Topology = m.topology.String()
+	st.Threads = m.topology.Threads()
+	for reason := range m.holds {
+		st.PausedBy = append(st.PausedBy, reason)
+	}
+	sort.Strings(st.PausedBy)
+	if len(m.caps) > 0 {
+		st.ThreadCaps = maps.Clone(m.caps)
+	}
+	st.Workers = make([]WorkerStatus, len(m.stats.Workers))
+	for i, ws := range m.stats.Workers {
+		st.Workers[i] = WorkerStatus{ID: i, Graphs: ws.Graphs.Total(), GraphsPerSec: ws.Graphs.Rates()[0]}
+		if m.placement != nil {
+			st.Workers[i].Placement = m.placement[i].String()
+		}
+	}
+	return st
+}
+
+func rates(r [len(stats.Windows)]float64) []float64 {
+	return r[:]
+}
+
+// Pause stops the workers but stays connected to the pool. Jobs that
+// arrive meanwhile are tracked, and Resume mines the latest one.
+func (m *Miner) Pause() {
+	m.setHold(holdUser, true)
+}
+
+// Resume restarts the workers after Pause. Mining stays paused while the
+// idle scheduler or thermal throttling still hold it.
+func (m *Miner) Resume() {
+	m.setHold(holdUser, false)
+}
+
+// Reasons mining is paused or its threads are capped. Several may apply at
+// once; the miner runs only with no pause holds, at the lowest cap.
+const (
+	holdUser = "user" // Pause, SIGUSR1 or the control API
+	holdIdle = "idle" // the idle scheduler
+)
+
+// setHold sets or clears a reason to pause, stopping or starting the
+// workers when that changes whether the miner is paused
+func (m *Miner) setHold(reason string, on bool) {
+	m.ctlMu.Lock()
+	defer m.ctlMu.Unlock()
+	if m.holds[reason] == on {
+		return
+	}
+	m.solversMu.Lock()
+	if on {
+		m.holds[reason] = true
+	} else {
+		delete(m.holds, reason)
+	}
+	paused := len(m.holds) > 0
+	m.solversMu.Unlock()
+	if paused == m.paused.Swap(paused) {
+		return
+	}
+	if paused {
+		m.stopWorkers()
+		m.logger.Info("Mining paused", zap.String("by", reason))
+		return
+	}
+	m.logger.Info("Mining resumed", zap.String("by", reason))
+	m.workMutex.RLock()
+	work := m.currentWork
+	m.workMutex.RUnlock()
+	if work != nil {
+		m.jobReceived = time.Now()
+		m.startWorkers()
+	}
+}
+
+// SetThreads resizes the solver pool to about n threads in total, laid out
+// as Start laid it out (see resizeTopology). The workers are
+// restarted on the current job; the pool connection stays up. Solvers are
+// created lazily by their workers. Thread caps still apply on top.
+func (m *Miner) SetThreads(n int) error {
+	if n < 1 {
+		return fmt.Errorf("threads must be at least 1, got %d", n)
+	}
+	m.ctlMu.Lock()
+	defer m.ctlMu.Unlock()
+	if m.solvers == nil {
+		return errors.New("miner not started")
+	}
+	m.solversMu.Lock()
+	m.threads = n
+	m.solversMu.Unlock()
+	return m.resize(m.threadLimit())
+}
+
+// setThreadCap limits the miner to n threads for reason; n <= 0 lifts it
+func (m *Miner) setThreadCap(reason string, n int) error {
+	m.ctlMu.Lock()
+	defer m.ctlMu.Unlock()
+	if m.solvers == nil {
+		return errors.New("miner not started")
+	}
+	m.solversMu.Lock()
+	if n > 0 {
+		m.caps[reason] = n
+	} else {
+		delete(m.caps, reason)
+	}
+	m.solversMu.Unlock()
+	return m.resize(m.threadLimit())
+}
+
+// threadLimit is the requested thread count under the lowest cap. The
+// caller holds ctlMu.
+func (m *Miner) threadLimit() int {
+	n := m.threads
+	for _, c := range m.caps {
+		n = min(n, c)
+	}
+	return n
+}
+
+// resize lays the solver pool out for n threads and restarts the workers
+// on the current job. The caller holds ctlMu.
+func (m *Miner) resize(n int) error {
+	topo := resizeTopology(m.layout, n, m.solverBE.MultiThreaded)
+	if topo == m.topology {
+		return nil
+	}
+	placement, err := resolvePlacement(m.cfg.Affinity, topo, sysinfo.Caches(3), sysinfo.NUMANodes())
+	if err != nil {
+		return err
+	}
+	// Available memory already excludes the current solvers, so only what
+	// the new layout adds has to fit
+	oldMem, errOld := m.solverBE.Memory(m.cfg.Params, m.topology.ThreadsPerSolver)
+	newMem, errNew := m.solverBE.Memory(m.cfg.Params, topo.ThreadsPerSolver)
+	if errOld == nil && errNew == nil {
+		oldTotal, newTotal := oldMem*uint64(m.topology.Solvers), newMem*uint64(topo.Solvers)
+		if avail := sysinfo.ReadMemory().Available; avail > 0 && newTotal > oldTotal && newTotal-oldTotal > avail {
+			m.logger.Warn("Solvers need more memory than is available",
+				zap.Uint64("bytesTotal", newTotal), zap.Uint64("bytesAvailable", avail+oldTotal))
+		}
+	}
+	m.stopWorkers()
+
+	m.solversMu.Lock()
+	for i, s := range m.solvers {
+		// Solvers of another width are rebuilt, surplus ones dropped
+		if s != nil && (i >= topo.Solvers || topo.ThreadsPerSolver != m.topology.ThreadsPerSolver) {
+			s.Close()
+			m.solvers[i] = nil
+		}
+	}
+	now := time.Now()
+	for len(m.solvers) < topo.Solvers {
+		m.solvers = append(m.solvers, nil)
+		m.batchers = append(m.batchers, newBatcher(m.cfg.SolveTime))
+		m.stats.Workers = append(m.stats.Workers, newWorkerStats(now))
+	}
+	m.solvers = m.solvers[:topo.Solvers]
+	m.batchers = m.batchers[:topo.Solvers]
+	m.stats.Workers = m.stats.Workers[:topo.Solvers:topo.Solvers]
+	m.topology, m.placement = topo, placement
+	m.solversMu.Unlock()
+
+	m.logger.Info("Solver topology changed", zap.Stringer("topology", topo), zap.Int("threads", topo.Threads()))
+	m.workMutex.RLock()
+	work := m.currentWork
+	m.workMutex.RUnlock()
+	if work != nil && !m.paused.Load() {
+		m.jobReceived = time.Now()
+		m.startWorkers()
+	}
+	return nil
+}
+
+// SwitchPool connects to another pool and, once connected, mines its jobs
+// instead. The old connection is closed; on error the miner keeps its pool.
+func (m *Miner) SwitchPool(addr, user, pass string) error {
+	c := m.newClient(addr, user, pass)
+	if err := c.Connect(); err != nil {
+		return fmt.Errorf("switch pool: %w", err)
+	}
+
+	m.ctlMu.Lock()
+	if m.stopped {
+		// Stop has closed the old client and won't close this one
+		m.ctlMu.Unlock()
+		c.Close()
+		return errors.New("switch pool: miner stopped")
+	}
+	old := m.client.Swap(c)
+	m.stopWorkers()
+	m.workMutex.Lock()
+	m.currentWork = nil
+	m.workMutex.Unlock()
+	m.ctlMu.Unlock()
+	old.Close()
+	m.stats.PoolSwitches.Add(1)
+	m.logger.Info("Switched pool", zap.String("from", old.Addr()), zap.String("to", addr), zap.String("user", user))
+	m.publish(c, events.Event{Type: events.PoolSwitched, From: old.Addr()})
+
+	// The first job may have arrived while connecting, before the switch
+	if work := c.GetWork(); work != nil {
+		m.handleNewWork(c, work)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestSetHold(t *testing.T) {
+	backend := &fakeBackend{solveTime: time.Millisecond}
+	m, c := newTestMiner(t, backend, Topology{2, 1}, time.Second)
+	m.handleNewWork(c, testWork("j1"))
+	waitFor(t, "workers to solve", func() bool { return backend.created.Load() == 2 })
+
+	for _, step := range []struct {
+		reason string
+		on     bool
+		paused bool
+	}{
+		{holdUser, true, true},
+		{holdIdle, true, true},
+		{holdUser, false, true}, // idle still holds it
+		{holdUser, false, true},
+		{holdIdle, false, false},
+		{holdThermal, true, true},
+		{holdThermal, false, false},
+	} {
+		m.setHold(step.reason, step.on)
+		if m.paused.Load() != step.paused || m.mining.Load() == step.paused {
+			t.Fatalf("setHold(%s, %v): paused %v, mining %v; want paused %v",
+				step.reason, step.on, m.paused.Load(), m.mining.Load(), step.paused)
+		}
+		if step.paused && backend.solving.Load() != 0 {
+			t.Fatalf("setHold(%s, %v): solves still running while paused", step.reason, step.on)
+		}
+	}
+}
+
+func TestPausedJobWaitsForResume(t *testing.T) {
+	backend := &fakeBackend{solveTime: time.Millisecond}
+	m, c := newTestMiner(t, backend, Topology{1, 1}, time.Second)
+	m.Pause()
+	m.handleNewWork(c, testWork("j1"))
+	if m.mining.Load() {
+		t.Fatal("workers started on a job while paused")
+	}
+	m.Resume()
+	waitFor(t, "the job to be mined", func() bool { return m.stats.Graphs.Total() > 0 })
+}
+
+func TestThreadControl(t *testing.T) {
+	backend := &fakeBackend{solveTime: time.Millisecond}
+	m, c := newTestMiner(t, backend, Topology{4, 1}, time.Second)
+	m.handleNewWork(c, testWork("j1"))
+
+	for _, step := range []struct {
+		name string
+		do   func() error
+		want Topology
+	}{
+		{"grow", func() error { return m.SetThreads(8) }, Topology{8, 1}},
+		{"cap", func() error { return m.setThreadCap(holdThermal, 3) }, Topology{3, 1}},
+		{"lower cap wins", func() error { return m.setThreadCap(holdIdle, 2) }, Topology{2, 1}},
+		{"threads under caps", func() error { return m.SetThreads(6) }, Topology{2, 1}},
+		{"lift one cap", func() error { return m.setThreadCap(holdIdle, 0) }, Topology{3, 1}},
+		{"lift all caps", func() error { return m.setThreadCap(holdThermal, 0) }, Topology{6, 1}},
+		{"shrink", func() error { return m.SetThreads(1) }, Topology{1, 1}},
+	} {
+		if err := step.do(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		m.solversMu.Lock()
+		topo, live := m.topology, 0
+		for _, s := range m.solvers {
+			if s != nil {
+				live++
+			}
+		}
+		sizes := [3]int{len(m.solvers), len(m.batchers), len(m.stats.Workers)}
+		m.solversMu.Unlock()
+		if topo != step.want {
+			t.Errorf("%s: topology %v, want %v", step.name, topo, step.want)
+		}
+		if sizes != [3]int{topo.Solvers, topo.Solvers, topo.Solvers} {
+			t.Errorf("%s: solvers, batchers and worker stats = %v, want %d each", step.name, sizes, topo.Solvers)
+		}
+		// Every solver dropped by a resize is closed
+		if open := int(backend.created.Load() - backend.closed.Load()); open != live {
+			t.Errorf("%s: %d solvers open, %d in use", step.name, open, live)
+		}
+		if !m.mining.Load() {
+			t.Errorf("%s: workers not restarted", step.name)
+		}
+	}
+}
+
+func TestNarrowThreads(t *testing.T) {
+	backend := &fakeBackend{solveTime: time.Millisecond}
+	m, c := newTestMiner(t, backend, Topology{4, 2}, time.Second)
+	m.handleNewWork(c, testWork("j1"))
+	for _, step := range []struct {
+		threads int
+		want    Topology
+	}{
+		{1, Topology{1, 1}}, // no solver of two threads is left running
+		{5, Topology{2, 2}},
+		{8, Topology{4, 2}}, // back to the width Start chose
+	} {
+		if err := m.SetThreads(step.threads); err != nil {
+			t.Fatal(err)
+		}
+		if got := m.Status(); got.Topology != step.want.String() || got.Threads != step.want.Threads() {
+			t.Errorf("SetThreads(%d): topology %s with %d threads, want %v", step.threads, got.Topology, got.Threads, step.want)
+		}
+	}
+}
+
+func TestThreadControlErrors(t *testing.T) {
+	m := NewMiner(Config{}, zap.NewNop())
+	if err := m.SetThreads(4); err == nil {
+		t.Error("SetThreads before Start: expected error")
+	}
+	if err := m.setThreadCap(holdIdle, 2); err == nil {
+		t.Error("setThreadCap before Start: expected error")
+	}
+	if err := m.SetThreads(0); err == nil {
+		t.Error("SetThreads(0): expected error")
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/nitrogen/go-miner/pkg/events"
+	"github.com/nitrogen/go-miner/pkg/stratum"
+)
+
+// startEvents subscribes the configured event sinks: the events file gets
+// every event, the webhook and hook command those in HookEvents
+func (m *Miner) startEvents() error {
+	if m.cfg.EventsFile != "" {
+		f, err := events.OpenFile(m.cfg.EventsFile)
+		if err != nil {
+			return err
+		}
+		m.events.Subscribe("file", f)
+	}
+	if m.cfg.Webhook != "" {
+		m.events.Subscribe("webhook", events.NewWebhook(m.cfg.Webhook), m.cfg.HookEvents...)
+	}
+	if m.cfg.EventHook != "" {
+		m.events.Subscribe("exec", &events.Exec{Command: m.cfg.EventHook}, m.cfg.HookEvents...)
+	}
+	return nil
+}
+
+// publish sends an event about client's pool
+func (m *Miner) publish(client *stratum.Client, e events.Event) {
+	e.Pool = client.Addr()
+	m.events.Publish(e)
+}
+
+// publishShare sends the outcome of a share submitted to client
+func (m *Miner) publishShare(client *stratum.Client, work *stratum.Work, latency time.Duration, err error) {
+	e := events.Event{
+		Type:       events.ShareAccepted,
+		JobID:      work.JobID,
+		Difficulty: client.GetDifficulty(),
+		LatencyMs:  float64(latency) / float64(time.Millisecond),
+	}
+	if err != nil {
+		e.Type = events.ShareRejected
+		e.Reason = err.Error()
+		var rej *stratum.RejectedError
+		if errors.As(err, &rej) {
+			e.Reason = rej.Reason
+		}
+	}
+	m.publish(client, e)
+}
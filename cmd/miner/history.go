@@ -0,0 +1,171 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nitrogen/go-miner/pkg/events"
+	"github.com/nitrogen/go-miner/pkg/history"
+	"go.uber.org/zap"
+)
+
+// historyFlushInterval is how often recorded shares and the current
+// session's end are written, so a crash loses at most this much history
+const historyFlushInterval = time.Minute
+
+// defaultHistoryPath is history.db in the user's config directory, where
+// `miner history` looks unless told otherwise
+func defaultHistoryPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "go-miner", "history.db")
+}
+
+// historyRecorder is the event sink that records shares and sessions. A
+// pool switch ends the session and starts one on the new pool. Shares wait
+// in memory for the next flush, which opens the file only to write them.
+type historyRecorder struct {
+	path   string
+	logger *zap.Logger
+
+	mu      sync.Mutex
+	shares  []history.Share // not yet written
+	session history.Session
+	closed  bool
+}
+
+// startHistory records to HistoryPath until the event bus closes. The
+// miner mines on without history if the file can't be written.
+func (m *Miner) startHistory(pool string) {
+	now := time.Now()
+	r := &historyRecorder{
+		path:    m.cfg.HistoryPath,
+		logger:  m.logger,
+		session: history.Session{Start: now, End: now, Pool: pool},
+	}
+	if err := r.flush(now); err != nil {
+		m.logger.Warn("Share history is off", zap.Error(err))
+		return
+	}
+	m.events.Subscribe("history", r, events.ShareAccepted, events.ShareRejected, events.PoolSwitched)
+	m.logger.Info("Recording share history", zap.String("path", m.cfg.HistoryPath))
+
+	go func() {
+		ticker := time.NewTicker(historyFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case now := <-ticker.C:
+				if err := r.flush(now); err != nil {
+					r.logger.Warn("Failed to write share history", zap.Error(err))
+				}
+			case <-m.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+func (r *historyRecorder) Handle(e events.Event) error {
+	if e.Type == events.PoolSwitched {
+		err := r.flush(e.Time)
+		r.mu.Lock()
+		r.session = history.Session{Start: e.Time, End: e.Time, Pool: e.Pool}
+		r.mu.Unlock()
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.shares = append(r.shares, history.Share{
+		Time:       e.Time,
+		Pool:       e.Pool,
+		JobID:      e.JobID,
+		Difficulty: e.Difficulty,
+		Accepted:   e.Type == events.ShareAccepted,
+		Reason:     e.Reason,
+		LatencyMs:  e.LatencyMs,
+	})
+	return nil
+}
+
+// Close writes what is left
+func (r *historyRecorder) Close() error {
+	err := r.flush(time.Now())
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+	return err
+}
+
+// flush extends the session to now and writes it with the waiting shares.
+// Shares that fail to be written wait for the next flush.
+func (r *historyRecorder) flush(now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.session.End = now
+	store, err := history.Open(r.path)
+	if err == nil {
+		err = store.Record(r.shares, r.session)
+		if cerr := store.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		return err
+	}
+	r.shares = r.shares[:0]
+	return nil
+}
+
+// runHistory implements `miner history`: daily accepted shares, reject
+// rates and uptime per pool
+func runHistory(args []string) error {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	var (
+		path = fs.String("db", defaultHistoryPath(), "History file the miner records to")
+		days = fs.Int("days", 7, "Days to show, including today")
+	)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s history [flags]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *days < 1 {
+		return fmt.Errorf("history: -days must be at least 1")
+	}
+	store, err := history.OpenReadOnly(*path)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	y, mo, d := time.Now().Date()
+	since := time.Date(y, mo, d-*days+1, 0, 0, 0, 0, time.Local)
+	rows, err := store.Daily(since, time.Local)
+	if err != nil {
+		return err
+	}
+	printHistoryTable(os.Stdout, rows)
+	return nil
+}
+
+func printHistoryTable(w io.Writer, rows []history.Day) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DATE\tPOOL\tACCEPTED\tREJECTED\tREJECT RATE\tUPTIME")
+	for _, d := range rows {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%.1f%%\t%s\n",
+			d.Date.Format("2006-01-02"), d.Pool, d.Accepted, d.Rejected, 100*d.RejectRate(), d.Uptime.Round(time.Second))
+	}
+	tw.Flush()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nitrogen/go-miner/pkg/events"
+	"github.com/nitrogen/go-miner/pkg/history"
+	"go.uber.org/zap"
+)
+
+func TestHistoryReadableWhileRecording(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+	r := &historyRecorder{
+		path:    filepath.Join(t.TempDir(), "history.db"),
+		logger:  zap.NewNop(),
+		session: history.Session{Start: start, End: start, Pool: "a:1"},
+	}
+	accepted := func() uint64 {
+		t.Helper()
+		store, err := history.OpenReadOnly(r.path)
+		if err != nil {
+			t.Fatalf("history not readable while recording: %v", err)
+		}
+		defer store.Close()
+		days, err := store.Daily(start.Add(-24*time.Hour), time.Local)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var n uint64
+		for _, d := range days {
+			n += d.Accepted
+		}
+		return n
+	}
+
+	if err := r.flush(start); err != nil {
+		t.Fatal(err)
+	}
+	r.Handle(events.Event{Type: events.ShareAccepted, Time: start.Add(time.Minute), Pool: "a:1"})
+	if n := accepted(); n != 0 {
+		t.Errorf("%d shares before the flush, want 0", n)
+	}
+	if err := r.flush(start.Add(2 * time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if n := accepted(); n != 1 {
+		t.Errorf("%d shares after the flush, want 1", n)
+	}
+	r.Handle(events.Event{Type: events.ShareAccepted, Time: start.Add(3 * time.Minute), Pool: "a:1"})
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if n := accepted(); n != 2 {
+		t.Errorf("%d shares after Close, want 2", n)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/nitrogen/go-miner/pkg/idle"
+	"github.com/nitrogen/go-miner/pkg/sysinfo"
+	"go.uber.org/zap"
+)
+
+// idleSampleInterval is how often the idle scheduler samples the load
+const idleSampleInterval = 5 * time.Second
+
+// loadAvgSettle is how long the load average is ignored after the miner's
+// thread count changes. The 1-minute average decays by e each minute, so
+// after three it holds 5% of the old count.
+const loadAvgSettle = 3 * time.Minute
+
+// runIdleScheduler pauses or throttles mining while other processes are
+// busy or outside mining hours, until the miner stops
+func (m *Miner) runIdleScheduler() {
+	sched := idle.New(m.cfg.Idle)
+	ticker := time.NewTicker(idleSampleInterval)
+	defer ticker.Stop()
+
+	state := idle.Run
+	prev, prevSelf := sysinfo.ReadCPUTimes(), sysinfo.ReadProcessCPU()
+	// Until settled, the load average still counts threads the miner ran
+	// before its last pause, resume or resize; /proc/stat deltas alone
+	// measure the others meanwhile
+	ours, settled := m.runningThreads(), time.Now().Add(loadAvgSettle)
+	for {
+		select {
+		case <-m.stopCh:
+			return
+		case now := <-ticker.C:
+			cur, self := sysinfo.ReadCPUTimes(), sysinfo.ReadProcessCPU()
+			load := othersLoad(prev, cur, self-prevSelf)
+			if n := m.runningThreads(); n != ours {
+				ours, settled = n, now.Add(loadAvgSettle)
+			}
+			if now.After(settled) {
+				load = max(load, othersRunQueue(sysinfo.ReadLoadAvg(), ours, runtime.NumCPU()))
+			}
+			prev, prevSelf = cur, self
+
+			next, why := sched.Update(now, load)
+			if next == state {
+				continue
+			}
+			m.logger.Info("Idle scheduler",
+				zap.Stringer("from", state), zap.Stringer("to", next),
+				zap.String("reason", why), zap.Float64("load", load))
+			state = next
+			m.applyIdleState(state)
+		}
+	}
+}
+
+// applyIdleState pauses or caps the miner as the idle scheduler decided
+func (m *Miner) applyIdleState(state idle.State) {
+	switch state {
+	case idle.Run:
+		m.setThreadCap(holdIdle, 0)
+		m.setHold(holdIdle, false)
+	case idle.Throttle:
+		if err := m.setThreadCap(holdIdle, m.cfg.IdleThrottle); err != nil {
+			m.logger.Warn("Failed to throttle", zap.Error(err))
+		}
+		m.setHold(holdIdle, false)
+	case idle.Pause:
+		m.setHold(holdIdle, true)
+	}
+}
+
+// othersLoad is the share of all CPUs that processes other than the miner
+// used between two samples; selfTicks is the miner's CPU time in between
+func othersLoad(prev, cur sysinfo.CPUTimes, selfTicks uint64) float64 {
+	if cur.Total <= prev.Total {
+		return 0
+	}
+	busy := float64(cur.Busy-prev.Busy) - float64(selfTicks)
+	return min(max(busy/float64(cur.Total-prev.Total), 0), 1)
+}
+
+// runningThreads is how many solver threads the miner runs: none while
+// paused, otherwise its topology's under the current caps
+func (m *Miner) runningThreads() int {
+	if m.paused.Load() {
+		return 0
+	}
+	m.solversMu.Lock()
+	defer m.solversMu.Unlock()
+	return m.topology.Threads()
+}
+
+// othersRunQueue is the share of CPUs other runnable tasks want, from the
+// 1-minute load average less the miner's threads. It is only meaningful
+// once the average has settled on the miner's current thread count.
+func othersRunQueue(loadAvg float64, ours, cpus int) float64 {
+	return max(loadAvg-float64(ours), 0) / float64(cpus)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/nitrogen/go-miner/pkg/sysinfo"
+)
+
+func TestOthersLoad(t *testing.T) {
+	prev := sysinfo.CPUTimes{Busy: 1000, Total: 4000}
+	for _, c := range []struct {
+		cur  sysinfo.CPUTimes
+		self uint64
+		want float64
+	}{
+		{sysinfo.CPUTimes{Busy: 1500, Total: 5000}, 0, 0.5},
+		{sysinfo.CPUTimes{Busy: 1500, Total: 5000}, 250, 0.25},
+		{sysinfo.CPUTimes{Busy: 1500, Total: 5000}, 800, 0}, // the miner's ticks were read later
+		{sysinfo.CPUTimes{Busy: 1000, Total: 4000}, 0, 0},   // no time passed
+	} {
+		if got := othersLoad(prev, c.cur, c.self); got != c.want {
+			t.Errorf("othersLoad(%v, %v, %d) = %v, want %v", prev, c.cur, c.self, got, c.want)
+		}
+	}
+}
+
+func TestOthersRunQueue(t *testing.T) {
+	for _, c := range []struct {
+		loadAvg float64
+		ours    int
+		want    float64
+	}{
+		{8, 8, 0},
+		{12, 8, 0.5},
+		{6, 8, 0}, // the solvers were not all runnable
+		{4, 0, 0.5},
+	} {
+		if got := othersRunQueue(c.loadAvg, c.ours, 8); got != c.want {
+			t.Errorf("othersRunQueue(%v, %d, 8) = %v, want %v", c.loadAvg, c.ours, got, c.want)
+		}
+	}
+}
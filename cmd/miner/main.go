@@ -4,115 +4,427 @@ import (
 	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
+	"math"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"runtime"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/nitrogen/go-miner/pkg/affinity"
+	"github.com/nitrogen/go-miner/pkg/events"
+	"github.com/nitrogen/go-miner/pkg/idle"
 	pkgsolver "github.com/nitrogen/go-miner/pkg/solver"
+	"github.com/nitrogen/go-miner/pkg/stats"
 	"github.com/nitrogen/go-miner/pkg/stratum"
+	"github.com/nitrogen/go-miner/pkg/sysinfo"
+	"github.com/nitrogen/go-miner/pkg/thermal"
+	"github.com/nitrogen/go-miner/pkg/workunit"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"golang.org/x/term"
 )
 
+// MinerStats are the miner's counters. Meters also keep 1m/5m/15m rates.
 type MinerStats struct {
-	StartTime      time.Time
-	CyclesTotal    atomic.Uint64
-	SolutionsTotal atomic.Uint64
-	SharesAccepted atomic.Uint64
-	SharesRejected atomic.Uint64
-	LastCycles     atomic.Uint64
-	LastSolutions  atomic.Uint64
-	LastTime       time.Time
+	StartTime      time.Time        // when the miner was created
+	Graphs         *stats.Meter     // graphs searched to the end; cancelled ones don't count
+	Cycles         *stats.Meter     // cycles found in those graphs
+	Shares         *stats.Meter     // shares the pool accepted
+	SharesRejected atomic.Uint64    // failed submissions
+	RejectReasons  *stats.Counters  // failed submissions by rejectReason
+	RestartLatency *stats.Histogram // seconds from a job's receipt to each worker solving it
+	RestartAborts  atomic.Uint64    // job switches that had to cancel running solves
+	PoolSwitches   atomic.Uint64    // successful SwitchPool calls
+	Workers        []*WorkerStats   // replaced under solversMu, only while the workers are stopped
+	CPUTemp        atomic.Uint64    // last reading in °C as math.Float64bits; NaN when unknown
+	ThermalLevel   atomic.Int32     // how far thermal throttling has stepped in
 }
 
+// WorkerStats are one worker's counters: the graphs it searched to the end
+// and the wall-clock time of its Solve calls in seconds
+type WorkerStats struct {
+	Graphs        *stats.Meter
+	SolveDuration *stats.Histogram
+}
+
+func newWorkerStats(now time.Time) *WorkerStats {
+	return &WorkerStats{
+		Graphs:        stats.NewMeter(now),
+		SolveDuration: stats.NewHistogram(stats.LatencyBuckets),
+	}
+}
+
+// Config is the miner's configuration
+type Config struct {
+	PoolAddr string
+	Username string
+	Password string
+	Threads  int              // total solver threads
+	Backend  string           // solver backend name, see pkgsolver.Backends
+	Params   pkgsolver.Params // graph size unless the job sets one
+	// Topology splits Threads into solvers: "SxT" for S solvers of T
+	// threads each, or "auto" (or empty) to size by L3 cache and memory
+	Topology string
+	// Affinity pins solvers to CPUs: "off", "auto" for one L3/NUMA domain
+	// per solver group, or ";"-separated CPU lists (see resolvePlacement)
+	Affinity string
+	// HugePages backs the C solvers' buffers with huge pages
+	HugePages pkgsolver.HugePages
+	// NoncesPerEN2 is how many nonces are searched under each extranonce2
+	// before moving on to the next; zero means the full 32-bit space
+	NoncesPerEN2 uint64
+	// SolveTime is the wall-clock time each Solve call aims for; the nonce
+	// batch is sized from the measured time per graph
+	SolveTime time.Duration
+	// MaxRestart is how long a new job waits for the running solves before
+	// cancelling them; zero cancels at once and a negative value never does
+	MaxRestart time.Duration
+	// MetricsAddr is where /metrics is served for Prometheus; empty for none
+	MetricsAddr string
+	// APIAddr is where the JSON control API is served; empty for none.
+	// APIToken, if set, must be sent as a bearer token.
+	APIAddr  string
+	APIToken string
+	// ClaymoreAddr is where Claymore's miner_getstat1 TCP API is served;
+	// empty for none
+	ClaymoreAddr string
+	// IdleScheduler enables backing off while the host is busy or outside
+	// Idle.Windows; IdleThrottle is the thread cap when Idle.Throttle is set
+	IdleScheduler bool
+	Idle          idle.Config
+	IdleThrottle  int
+	// Thermal throttles mining while the CPU sensor reads Thermal.High or
+	// more; zero High disables it. ThermalSensor is a temp*_input file or a
+	// hwmon-style directory to search (default /sys/class/hwmon).
+	// ThermalDuty pauses for part of each period instead of capping threads.
+	Thermal       thermal.Config
+	ThermalSensor string
+	ThermalDuty   bool
+	// ShutdownTimeout bounds how long Stop waits for the running solves
+	// and the pool's answers to their shares
+	ShutdownTimeout time.Duration
+	// EventsFile is a JSON-lines file every event is appended to. Webhook
+	// is a URL events are POSTed to and EventHook a shell command run for
+	// each; both get only HookEvents, or all events if it is empty.
+	EventsFile string
+	Webhook    string
+	EventHook  string
+	HookEvents []events.Type
+	// HistoryPath is the file shares and sessions are recorded to; empty
+	// for none
+	HistoryPath string
+}
+
+// defaultNoncesPerEN2 moves to a new extranonce2, and so a new header,
+// after every nonce: the graph depends only on the header, so more nonces
+// under one extranonce2 would search the same graph again. Pools that key
+// graphs by nonce can raise it with -en2-nonces, and the batchers then size
+// each Solve within it.
+const defaultNoncesPerEN2 = 1
+
+// headerRetryDelay is how long a worker backs off after failing to build
+// a header, which won't get better until the next job
+const headerRetryDelay = time.Second
+
 type Miner struct {
-	// Configuration
-	poolAddr string
-	username string
-	password string
-	threads  int
+	cfg Config
 
 	// Components
-	client  *stratum.Client
-	solvers []*pkgsolver.Solver
-	logger  *zap.Logger
+	client    atomic.Pointer[stratum.Client] // replaced by SwitchPool
+	solverBE  pkgsolver.BackendInfo
+	topology  Topology
+	layout    Topology    // as Start laid it out; resizes keep its solver width
+	placement []Placement // per solver; nil when not pinned
+	solvers   []pkgsolver.Backend
+	batchers  []*batcher // per worker; kept across jobs
+	solversMu sync.Mutex
+	logger    *zap.Logger
 
 	// State
-	currentWork *stratum.Work
-	workMutex   sync.RWMutex
-	extraNonce2 atomic.Uint64
-	mining      atomic.Bool
+	currentWork  *stratum.Work
+	workMutex    sync.RWMutex
+	units        *workunit.Allocator // (extranonce2, nonce range) slices of the current job
+	exhaustedJob string              // job whose exhaustion was logged; guarded by workMutex
+	jobReceived  time.Time           // when the workers' job arrived; set before they start
+	mining       atomic.Bool
+	paused       atomic.Bool    // some pause hold is set
+	submitting   atomic.Int32   // shares sent to the pool and not yet answered
+	submits      sync.WaitGroup // submitShare goroutines
+
+	// ctlMu serializes what starts and stops the workers: job switches,
+	// pause holds, resizing, SwitchPool and Stop. holds, caps and threads
+	// are also written under solversMu, so Status can read them.
+	ctlMu   sync.Mutex
+	stopped bool            // guarded by ctlMu
+	holds   map[string]bool // reasons mining is paused, see setHold
+	caps    map[string]int  // thread caps by reason, see setThreadCap
+	threads int             // threads asked for, before caps
 
 	// Statistics
 	stats MinerStats
 
 	// Control
-	stopCh chan struct{}
-	wg     sync.WaitGroup
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+	metrics  *http.Server
+	api      *http.Server
+	claymore net.Listener
+	events   *events.Bus
+}
+
+func NewMiner(cfg Config, logger *zap.Logger) *Miner {
+	m := &Miner{
+		cfg:    cfg,
+		logger: logger,
+		units:  workunit.NewAllocator(cfg.NoncesPerEN2),
+		holds:  make(map[string]bool),
+		caps:   make(map[string]int),
+		stopCh: make(chan struct{}),
+		stats:  newMinerStats(time.Now()),
+		events: events.NewBus(logger),
+	}
+	m.stats.CPUTemp.Store(math.Float64bits(math.NaN()))
+	return m
 }
 
-func NewMiner(poolAddr, username, password string, threads int, logger *zap.Logger) *Miner {
-	return &Miner{
-		poolAddr: poolAddr,
-		username: username,
-		password: password,
-		threads:  threads,
-		logger:   logger,
-		stopCh:   make(chan struct{}),
-		stats: MinerStats{
-			StartTime: time.Now(),
-			LastTime:  time.Now(),
-		},
+func newMinerStats(now time.Time) MinerStats {
+	return MinerStats{
+		StartTime: now,
+		Graphs:    stats.NewMeter(now),
+		Cycles:    stats.NewMeter(now),
+		Shares:    stats.NewMeter(now),
+
+		RejectReasons:  stats.NewCounters(),
+		RestartLatency: stats.NewHistogram(stats.LatencyBuckets),
 	}
 }
 
-func (m *Miner) Start() error {
+func (m *Miner) Start() (err error) {
 	m.logger.Info("Starting miner",
-		zap.String("pool", m.poolAddr),
-		zap.String("user", m.username),
-		zap.Int("threads", m.threads))
+		zap.String("pool", m.cfg.PoolAddr),
+		zap.String("user", m.cfg.Username),
+		zap.Int("threads", m.cfg.Threads),
+		zap.String("solver", m.cfg.Backend),
+		zap.Stringer("params", m.cfg.Params))
+
+	info, err := pkgsolver.LookupBackend(m.cfg.Backend)
+	if err != nil {
+		return err
+	}
+	m.solverBE = info
+	pkgsolver.SetHugePages(m.cfg.HugePages)
+
+	m.topology, err = resolveTopology(m.cfg.Topology, info, m.cfg.Params, m.cfg.Threads,
+		sysinfo.L3Bytes(), sysinfo.ReadMemory())
+	if err != nil {
+		return err
+	}
+
+	// Report memory before committing to it; mean needs GBs per graph
+	workers, perSolver := m.topology.Solvers, m.topology.ThreadsPerSolver
+	mem, err := info.Memory(m.cfg.Params, perSolver)
+	if err != nil {
+		return err
+	}
+	m.logger.Info("Solver topology",
+		zap.Stringer("topology", m.topology),
+		zap.Int("solvers", workers),
+		zap.Int("threadsPerSolver", perSolver),
+		zap.Uint64("bytesPerSolver", mem),
+		zap.Uint64("bytesTotal", mem*uint64(workers)))
+	if avail := sysinfo.ReadMemory().Available; avail > 0 && mem*uint64(workers) > avail {
+		m.logger.Warn("Solvers need more memory than is available",
+			zap.Uint64("bytesTotal", mem*uint64(workers)), zap.Uint64("bytesAvailable", avail))
+	}
+
+	m.placement, err = resolvePlacement(m.cfg.Affinity, m.topology, sysinfo.Caches(3), sysinfo.NUMANodes())
+	if err != nil {
+		return err
+	}
+	if m.placement == nil && m.cfg.Affinity == "auto" {
+		m.logger.Info("CPU layout unknown; solvers are not pinned")
+	}
+	for i, p := range m.placement {
+		m.logger.Info("Solver placement", zap.Int("solver", i), zap.Stringer("placement", p))
+	}
+
+	// From here on Start acquires resources; on error it releases them all
+	defer func() {
+		if err != nil {
+			m.shutdown()
+		}
+	}()
 
 	// Initialize solvers
-	m.solvers = make([]*pkgsolver.Solver, m.threads)
-	for i := 0; i < m.threads; i++ {
-		m.solvers[i] = pkgsolver.NewSolver(1) // Each solver single-threaded
+	m.layout = m.topology
+	m.threads = m.topology.Threads()
+	m.solvers = make([]pkgsolver.Backend, workers)
+	m.batchers = make([]*batcher, workers)
+	m.stats.Workers = make([]*WorkerStats, workers)
+	for i := range m.batchers {
+		m.batchers[i] = newBatcher(m.cfg.SolveTime)
+		m.stats.Workers[i] = newWorkerStats(time.Now())
+	}
+	for i := 0; i < workers; i++ {
+		s, err := info.New(m.cfg.Params, perSolver)
+		if err != nil {
+			return fmt.Errorf("failed to create solver %d: %w", i, err)
+		}
+		m.solvers[i] = s
 	}
 
 	// Create Stratum client
-	m.client = stratum.NewClient(m.poolAddr, m.username, m.password, m.logger)
-	m.client.SetWorkHandler(m.handleNewWork)
-	m.client.SetReconnectHandler(m.handleReconnect)
+	client := m.newClient(m.cfg.PoolAddr, m.cfg.Username, m.cfg.Password)
+	m.client.Store(client)
+
+	if m.cfg.MetricsAddr != "" {
+		if err := m.serveMetrics(m.cfg.MetricsAddr); err != nil {
+			return err
+		}
+	}
+	if m.cfg.APIAddr != "" {
+		if err := m.serveAPI(m.cfg.APIAddr, m.cfg.APIToken); err != nil {
+			return err
+		}
+	}
+	if m.cfg.ClaymoreAddr != "" {
+		if err := m.serveClaymore(m.cfg.ClaymoreAddr); err != nil {
+			return err
+		}
+	}
+	if err := m.startEvents(); err != nil {
+		return err
+	}
+	if m.cfg.HistoryPath != "" {
+		m.startHistory(m.cfg.PoolAddr)
+	}
 
 	// Connect to pool
-	if err := m.client.Connect(); err != nil {
+	if err := client.Connect(); err != nil {
 		return fmt.Errorf("failed to connect: %w", err)
 	}
 
 	// Start stats printer
 	go m.printStats()
+	if m.cfg.IdleScheduler {
+		go m.runIdleScheduler()
+	}
+	if m.cfg.Thermal.High > 0 {
+		if sensor, err := m.thermalSensor(); err != nil {
+			m.logger.Warn("No CPU temperature sensor; thermal throttling is off", zap.Error(err))
+		} else {
+			m.logger.Info("Thermal throttling", zap.String("sensor", sensor),
+				zap.Float64("highC", m.cfg.Thermal.High), zap.Float64("lowC", m.cfg.Thermal.Low))
+			go m.runThermal(sensor)
+		}
+	}
 
 	return nil
 }
 
+// Stop shuts the miner down: no new solves start, the running ones are
+// cancelled and the shares they found are submitted. The pool connection
+// stays up until the workers are done and those shares answered, or
+// ShutdownTimeout passes.
 func (m *Miner) Stop() {
-	m.logger.Info("Stopping miner...")
+	m.logger.Info("Stopping miner...", zap.Duration("timeout", m.cfg.ShutdownTimeout))
+	m.shutdown()
+	m.logSummary()
+}
+
+// shutdown releases what Start acquired, as far as it got: servers, the
+// workers and their solvers, the pool connection and the event sinks
+func (m *Miner) shutdown() {
+	m.ctlMu.Lock()
+	m.stopped = true
 	m.mining.Store(false)
-	// Request cancellation of running solves first
+	m.ctlMu.Unlock()
+	close(m.stopCh)
+	if m.metrics != nil {
+		m.metrics.Close()
+	}
+	if m.api != nil {
+		m.api.Close()
+	}
+	if m.claymore != nil {
+		m.claymore.Close()
+	}
+
+	deadline := time.Now().Add(m.cfg.ShutdownTimeout)
+	timer := time.NewTimer(m.cfg.ShutdownTimeout)
+	defer timer.Stop()
+	done := m.cancelUntil(m.workersDone(), timer.C)
+	if !done {
+		m.logger.Warn("Shutdown timed out; abandoning running solves",
+			zap.Int32("pendingShares", m.submitting.Load()))
+	} else {
+		// The workers are gone, so no more shares are sent; wait for the
+		// pool to answer those that were
+		submitted := make(chan struct{})
+		go func() {
+			m.submits.Wait()
+			close(submitted)
+		}()
+		select {
+		case <-submitted:
+		case <-timer.C:
+			m.logger.Warn("Shutdown timed out; abandoning unanswered shares",
+				zap.Int32("pendingShares", m.submitting.Load()))
+		}
+	}
+	if c := m.client.Load(); c != nil {
+		c.Close()
+	}
+	// A solve that ignored the cancel may still use its solver's memory
+	if done {
+		m.closeSolvers()
+	}
+	if !m.events.Close(time.Until(deadline)) {
+		m.logger.Warn("Shutdown timed out; some events were not delivered")
+	}
+}
+
+// logSummary logs the totals of the run
+func (m *Miner) logSummary() {
+	uptime := time.Since(m.stats.StartTime)
+	graphs := m.stats.Graphs.Total()
+	m.logger.Info("Miner stopped",
+		zap.Duration("uptime", uptime.Round(time.Second)),
+		zap.Uint64("totalGraphs", graphs),
+		zap.Float64("avgGraphs/s", float64(graphs)/uptime.Seconds()),
+		zap.Uint64("totalCycles", m.stats.Cycles.Total()),
+		zap.Uint64("sharesAccepted", m.stats.Shares.Total()),
+		zap.Uint64("sharesRejected", m.stats.SharesRejected.Load()),
+		zap.Any("rejectReasons", m.stats.RejectReasons.Snapshot()),
+		zap.Uint64("restartAborts", m.stats.RestartAborts.Load()),
+		zap.Uint64("poolSwitches", m.stats.PoolSwitches.Load()),
+	)
+}
+
+// cancelSolves aborts the solves in progress
+func (m *Miner) cancelSolves() {
+	m.solversMu.Lock()
+	defer m.solversMu.Unlock()
 	for _, s := range m.solvers {
 		if s != nil {
 			s.Cancel()
 		}
 	}
-	close(m.stopCh)
-	m.client.Close()
-	m.wg.Wait()
-	// Release solver resources
+}
+
+// closeSolvers releases C-side resources of all solvers
+func (m *Miner) closeSolvers() {
+	m.solversMu.Lock()
+	defer m.solversMu.Unlock()
 	for _, s := range m.solvers {
 		if s != nil {
 			s.Close()
@@ -120,8 +432,70 @@ func (m *Miner) Stop() {
 	}
 }
 
-func (m *Miner) handleNewWork(work *stratum.Work) {
+// workerSolver returns the worker's solver, rebuilding it when the job asks
+// for a different graph size than the one it was created for
+func (m *Miner) workerSolver(workerID int, params pkgsolver.Params) (pkgsolver.Backend, error) {
+	m.solversMu.Lock()
+	defer m.solversMu.Unlock()
+
+	cur := m.solvers[workerID]
+	if cur != nil && cur.Capabilities().Params == params {
+		return cur, nil
+	}
+	s, err := m.solverBE.New(params, m.topology.ThreadsPerSolver)
+	if err != nil {
+		return nil, err
+	}
+	m.solvers[workerID] = s
+	if cur == nil {
+		// Added by SetThreads
+		m.logger.Info("Solver created", zap.Int("worker", workerID), zap.Stringer("params", params))
+		return s, nil
+	}
+	cur.Close()
+	m.logger.Info("Solver rebuilt for job graph size",
+		zap.Int("worker", workerID), zap.Stringer("params", params))
+	return s, nil
+}
+
+// jobParams returns the graph parameters for work, falling back to config
+func (m *Miner) jobParams(work *stratum.Work) pkgsolver.Params {
+	params := m.cfg.Params
+	if work.EdgeBits > 0 {
+		params.EdgeBits = work.EdgeBits
+	}
+	if work.ProofSize > 0 {
+		params.ProofSize = work.ProofSize
+	}
+	return params
+}
+
+// newClient returns a stratum client wired to the miner
+func (m *Miner) newClient(addr, user, pass string) *stratum.Client {
+	c := stratum.NewClient(addr, user, pass, m.logger)
+	c.SetCuckooParams(m.cfg.Params.EdgeBits, m.cfg.Params.ProofSize)
+	c.SetWorkHandler(func(work *stratum.Work) { m.handleNewWork(c, work) })
+	c.SetReconnectHandler(func() { m.handleReconnect(c) })
+	c.SetDifficultyHandler(func(diff float64) {
+		m.publish(c, events.Event{Type: events.DifficultyChanged, Difficulty: diff})
+	})
+	c.SetDisconnectHandler(func(err error) {
+		m.publish(c, events.Event{Type: events.Disconnected, Reason: err.Error()})
+	})
+	return c
+}
+
+// handleNewWork switches the workers to a job from client. Jobs from a pool
+// the miner has switched away from are ignored.
+func (m *Miner) handleNewWork(client *stratum.Client, work *stratum.Work) {
+	received := time.Now()
+	m.ctlMu.Lock()
+	defer m.ctlMu.Unlock()
+	if m.client.Load() != client {
+		return
+	}
 	m.logger.Info("New work received", zap.String("jobID", work.JobID))
+	m.publish(client, events.Event{Type: events.JobReceived, JobID: work.JobID, Difficulty: client.GetDifficulty()})
 
 	// Update current work
 	m.workMutex.Lock()
@@ -129,30 +503,113 @@ func (m *Miner) handleNewWork(work *stratum.Work) {
 	m.workMutex.Unlock()
 
 	// Stop existing mining
-	m.mining.Store(false)
-	m.wg.Wait()
+	m.stopWorkers()
+
+	// A job sent again (e.g. after a reconnect) resumes where it stopped
+	m.units.Start(workunit.Job{
+		ID:              work.JobID,
+		ExtraNonce1:     work.ExtraNonce1,
+		ExtraNonce2Size: work.ExtraNonce2Size,
+	}, work.CleanJobs)
 
 	// Start new mining
+	m.jobReceived = received
+	if !m.paused.Load() {
+		m.startWorkers()
+	}
+}
+
+// startWorkers starts one worker per solver on the current job. The caller
+// holds ctlMu and has set jobReceived.
+func (m *Miner) startWorkers() {
+	if m.stopped {
+		return
+	}
 	m.mining.Store(true)
-	for i := 0; i < m.threads; i++ {
+	for i := 0; i < len(m.solvers); i++ {
 		m.wg.Add(1)
 		go m.mineWorker(i)
 	}
 }
 
-func (m *Miner) handleReconnect() {
+// stopWorkers stops the workers and waits for them to exit. Solves still
+// running after MaxRestart are cancelled: the nonces they did not reach go
+// back to the allocator, so only the graphs in progress are lost.
+func (m *Miner) stopWorkers() {
+	m.mining.Store(false)
+	done := m.workersDone()
+	if m.cfg.MaxRestart < 0 {
+		<-done
+		return
+	}
+	timer := time.NewTimer(m.cfg.MaxRestart)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return
+	case <-timer.C:
+	}
+
+	m.stats.RestartAborts.Add(1)
+	m.cancelUntil(done, nil)
+}
+
+// workersDone returns a channel closed once the workers have exited
+func (m *Miner) workersDone() <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+	return done
+}
+
+// cancelUntil cancels the running solves until done is closed, or reports
+// false if timeout fires first; a nil timeout never does. It keeps
+// cancelling because a worker between two solves would clear an abort when
+// it starts the next.
+func (m *Miner) cancelUntil(done <-chan struct{}, timeout <-chan time.Time) bool {
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	for {
+		m.cancelSolves()
+		select {
+		case <-done:
+			return true
+		case <-timeout:
+			select {
+			case <-done:
+				return true
+			default:
+				return false
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Miner) handleReconnect(client *stratum.Client) {
 	m.logger.Info("Reconnected to pool")
+	m.publish(client, events.Event{Type: events.Reconnected})
 	// Mining will resume when new work arrives
 }
 
 func (m *Miner) mineWorker(workerID int) {
 	defer m.wg.Done()
 
-	solver := m.solvers[workerID]
-
-	// Track worker's current base nonce across iterations
-	var currentNonce uint32 = uint32(workerID) * (1 << 24)
+	// Pin the thread that drives the solver; the solver's own threads and
+	// memory policy are inherited from it. It is never unlocked, so the
+	// pinned thread exits with the worker instead of returning to the pool.
+	if m.placement != nil {
+		runtime.LockOSThread()
+		p := m.placement[workerID]
+		if err := affinity.Pin(p.CPUs, p.Node); err != nil {
+			m.logger.Warn("Failed to pin solver", zap.Int("worker", workerID), zap.Error(err))
+		}
+	}
 
+	batch, ws := m.batchers[workerID], m.stats.Workers[workerID]
+	restarted := false
 	for m.mining.Load() {
 		// Get current work
 		m.workMutex.RLock()
@@ -164,14 +621,29 @@ func (m *Miner) mineWorker(workerID int) {
 			continue
 		}
 
-		// Generate extraNonce2
-		en2Counter := m.extraNonce2.Add(1)
-		extraNonce2 := stratum.GenerateExtraNonce2(work.ExtraNonce2Size, en2Counter)
+		unit, err := m.units.Next(batch.size())
+		if errors.Is(err, workunit.ErrExhausted) {
+			m.workMutex.Lock()
+			if m.exhaustedJob != work.JobID {
+				m.exhaustedJob = work.JobID
+				m.logger.Warn("Search space of job exhausted; waiting for new work", zap.String("jobID", work.JobID))
+			}
+			m.workMutex.Unlock()
+			return
+		}
+		if unit.JobID != work.JobID {
+			// The job changed under us; the restart is on its way
+			m.units.Return(unit, 0)
+			continue
+		}
+		extraNonce2 := stratum.GenerateExtraNonce2(work.ExtraNonce2Size, unit.ExtraNonce2)
 
 		// Build header
 		header, coinbaseHex, merkleHex, err := stratum.BuildHeaderWithDebug(work, extraNonce2)
 		if err != nil {
 			m.logger.Error("Failed to build header", zap.Error(err))
+			m.units.Return(unit, 0)
+			m.sleep(headerRetryDelay)
 			continue
 		}
 		m.logger.Debug("Header inputs", zap.String("coinbase", coinbaseHex), zap.String("merkle", merkleHex))
@@ -180,6 +652,8 @@ func (m *Miner) mineWorker(workerID int) {
 		// Debug: check header
 		if len(header) != 80 {
 			m.logger.Error("Invalid header length", zap.Int("length", len(header)))
+			m.units.Return(unit, 0)
+			m.sleep(headerRetryDelay)
 			continue
 		}
 
@@ -197,14 +671,37 @@ func (m *Miner) mineWorker(workerID int) {
 		// Update nTime if needed
 		ntime := work.NTime
 
-		// Set header for solver
-		solver.SetHeader(header)
+		solver, err := m.workerSolver(workerID, m.jobParams(work))
+		if err != nil {
+			m.logger.Error("No solver for job", zap.String("jobID", work.JobID), zap.Error(err))
+			return
+		}
 
-		// Mine with nonce range
-		baseNonce := currentNonce     // Continue from last position
-		nonceRange := uint32(1 << 10) // 1024 nonces per iteration for frequent updates
+		// Set header for solver
+		if err := solver.SetHeader(header); err != nil {
+			m.logger.Error("Failed to set header", zap.Error(err))
+			return
+		}
 
-		solutions := solver.Solve(baseNonce, nonceRange)
+		// Mine the unit's nonce range
+		baseNonce := unit.BaseNonce
+		start := time.Now()
+		if !restarted {
+			restarted = true
+			m.stats.RestartLatency.Observe(start.Sub(m.jobReceived).Seconds())
+		}
+		solutions, err := solver.Solve(baseNonce, unit.NonceRange)
+		elapsed := time.Since(start)
+		ws.SolveDuration.Observe(elapsed.Seconds())
+		// Graphs are searched in nonce order, so what the solver did not get
+		// to (it was cancelled, or searches fewer graphs per call) goes back
+		graphs := solver.LastGraphs()
+		m.units.Return(unit, uint32(graphs))
+		if err != nil {
+			m.logger.Error("Solve failed", zap.Error(err))
+			return
+		}
+		batch.observe(elapsed, graphs)
 
 		// Check and submit solutions
 		for _, sol := range solutions {
@@ -213,7 +710,7 @@ func (m *Miner) mineWorker(workerID int) {
 			hash := pkgsolver.HashSolution(header, baseNonce, sol.Nonce)
 			// Prefer pool difficulty target; fallback to compact nBits
 			var target []byte
-			poolDiff := m.client.GetDifficulty()
+			poolDiff := m.client.Load().GetDifficulty()
 			if poolDiff > 0 {
 				target = stratum.DifficultyToTarget(poolDiff)
 			}
@@ -231,24 +728,14 @@ func (m *Miner) mineWorker(workerID int) {
 			}
 
 			if stratum.CheckTarget(hash[:], target) {
-				// Submit solution
-				err := m.client.SubmitWork(work, extraNonce2, ntime, baseNonce, sol.Nonce)
-				if err != nil {
-					m.logger.Error("Failed to submit work", zap.Error(err))
-					m.stats.SharesRejected.Add(1)
-				} else {
-					m.logger.Info("Share accepted!")
-					m.stats.SharesAccepted.Add(1)
-				}
+				m.submitShare(work, extraNonce2, ntime, baseNonce, sol.Nonce)
 			}
 		}
 
 		// Update stats
-		m.stats.CyclesTotal.Add(uint64(nonceRange))
-		m.stats.SolutionsTotal.Add(uint64(len(solutions)))
-
-		// Advance base nonce for next iteration (wrap-around on overflow is fine)
-		currentNonce += nonceRange
+		m.stats.Graphs.Add(uint64(graphs))
+		ws.Graphs.Add(uint64(graphs))
+		m.stats.Cycles.Add(uint64(len(solutions)))
 
 		// Check if we should continue with same work
 		if !m.mining.Load() {
@@ -259,6 +746,31 @@ func (m *Miner) mineWorker(workerID int) {
 	}
 }
 
+// submitShare sends a share to the pool in the background: the answer may
+// take up to the stratum timeout, and neither the worker nor a job switch
+// waiting for the workers should wait for it. Shutdown waits for the
+// answers, within ShutdownTimeout.
+func (m *Miner) submitShare(work *stratum.Work, extraNonce2, ntime string, nonce uint32, cycle []uint32) {
+	client := m.client.Load()
+	m.submitting.Add(1)
+	m.submits.Add(1)
+	go func() {
+		defer m.submits.Done()
+		sent := time.Now()
+		err := client.SubmitWork(work, extraNonce2, ntime, nonce, cycle)
+		m.submitting.Add(-1)
+		m.publishShare(client, work, time.Since(sent), err)
+		if err != nil {
+			m.logger.Error("Failed to submit work", zap.Error(err))
+			m.stats.SharesRejected.Add(1)
+			m.stats.RejectReasons.Add(rejectReason(err), 1)
+		} else {
+			m.logger.Info("Share accepted!")
+			m.stats.Shares.Add(1)
+		}
+	}()
+}
+
 func (m *Miner) printStats() {
 	ticker := time.NewTicker(10 * time.Second)
 	defer ticker.Stop()
@@ -267,28 +779,35 @@ func (m *Miner) printStats() {
 		select {
 		case <-ticker.C:
 			now := time.Now()
-			elapsed := now.Sub(m.stats.LastTime).Seconds()
-
-			cycles := m.stats.CyclesTotal.Load()
-			solutions := m.stats.SolutionsTotal.Load()
-			lastCycles := m.stats.LastCycles.Load()
-			lastSolutions := m.stats.LastSolutions.Load()
-
-			cyclesPerSec := float64(cycles-lastCycles) / elapsed
-			solutionsPerSec := float64(solutions-lastSolutions) / elapsed
+			graphsPerSec := m.stats.Graphs.Tick(now)
+			cyclesPerSec := m.stats.Cycles.Tick(now)
+			m.stats.Shares.Tick(now)
+			m.solversMu.Lock()
+			for _, ws := range m.stats.Workers {
+				ws.Graphs.Tick(now)
+			}
+			m.solversMu.Unlock()
 
 			m.logger.Info("Miner stats",
+				zap.Float64("graphs/s", graphsPerSec),
+				zap.String("graphs/s 1m/5m/15m", formatRates(m.stats.Graphs.Rates(), 1)),
 				zap.Float64("cycles/s", cyclesPerSec),
-				zap.Float64("solutions/s", solutionsPerSec),
-				zap.Uint64("totalCycles", cycles),
-				zap.Uint64("totalSolutions", solutions),
-				zap.Uint64("sharesAccepted", m.stats.SharesAccepted.Load()),
+				zap.String("cycles/s 1m/5m/15m", formatRates(m.stats.Cycles.Rates(), 1)),
+				zap.String("shares/min 1m/5m/15m", formatRates(m.stats.Shares.Rates(), 60)),
+				zap.Uint64("totalGraphs", m.stats.Graphs.Total()),
+				zap.Uint64("totalCycles", m.stats.Cycles.Total()),
+				zap.Uint64("sharesAccepted", m.stats.Shares.Total()),
 				zap.Uint64("sharesRejected", m.stats.SharesRejected.Load()),
+				zap.String("restart ms p50/p99/max", formatLatency(m.stats.RestartLatency.Snapshot())),
+				zap.Uint64("restartAborts", m.stats.RestartAborts.Load()),
 			)
-
-			m.stats.LastCycles.Store(cycles)
-			m.stats.LastSolutions.Store(solutions)
-			m.stats.LastTime = now
+			if m.cfg.HugePages != pkgsolver.HugePagesOff {
+				m.logHugePages()
+			}
+			if temp, ok := m.cpuTemp(); ok {
+				m.logger.Info("CPU temperature",
+					zap.Float64("tempC", temp), zap.Int32("thermalLevel", m.stats.ThermalLevel.Load()))
+			}
 
 		case <-m.stopCh:
 			return
@@ -296,7 +815,57 @@ func (m *Miner) printStats() {
 	}
 }
 
+// logHugePages reports how much solver memory huge pages actually back;
+// the kernel may grant fewer than asked for
+func (m *Miner) logHugePages() {
+	var total pkgsolver.HugePageStatus
+	m.solversMu.Lock()
+	for _, s := range m.solvers {
+		if hr, ok := s.(pkgsolver.HugePageReporter); ok {
+			st := hr.HugePages()
+			total.Bytes += st.Bytes
+			total.HugeTLB += st.HugeTLB
+			total.THP += st.THP
+		}
+	}
+	m.solversMu.Unlock()
+	m.logger.Info("Huge pages",
+		zap.Stringer("mode", m.cfg.HugePages),
+		zap.Bool("inEffect", total.Huge() > 0),
+		zap.Uint64("bytesHugeTLB", total.HugeTLB),
+		zap.Uint64("bytesTHP", total.THP),
+		zap.Uint64("bytesSolver", total.Bytes))
+}
+
+// formatRates renders 1m/5m/15m rates per second, scaled (60 for per minute)
+func formatRates(r [len(stats.Windows)]float64, scale float64) string {
+	return fmt.Sprintf("%.2f/%.2f/%.2f", r[0]*scale, r[1]*scale, r[2]*scale)
+}
+
+// formatLatency renders a latency histogram's p50/p99/max in milliseconds
+func formatLatency(s stats.HistogramSnapshot) string {
+	if s.Count == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.1f/%.1f/%.1f", s.Quantile(0.5)*1e3, s.Quantile(0.99)*1e3, s.Max*1e3)
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		if err := runBench(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "history" {
+		if err := runHistory(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Hardcoded configuration
 	const (
 		WALLET    = "4BdyC3wW6BJiqCNp9Tdr2D9gVnBiVfFnCH"
@@ -310,9 +879,93 @@ func main() {
 		worker  = flag.String("u", "CPU-666", "Worker name")
 		threads = flag.Int("t", runtime.NumCPU(), "Number of mining threads (default: all cores)")
 		debug   = flag.Bool("debug", false, "Enable debug logging")
+		tui     = flag.Bool("tui", false, "Show a full-screen dashboard instead of log lines")
+		solver  = flag.String("solver", pkgsolver.DefaultBackend, "Solver backend: "+strings.Join(pkgsolver.Backends(), ", "))
+		topo    = flag.String("topology", "auto", "Solvers x threads per solver, e.g. 4x8 (overrides -t), or auto")
+		huge    = flag.String("hugepages", "off", "Back solver memory with huge pages: off, thp or hugetlb (falls back to thp)")
+		en2     = flag.Uint64("en2-nonces", defaultNoncesPerEN2, "Nonces to search under each extranonce2 before the next (0 = all 2^32)")
+		solveT  = flag.Duration("solve-time", 250*time.Millisecond, "Wall-clock time each Solve call aims for")
+		metrics = flag.String("metrics", "", "Serve Prometheus metrics at this address's /metrics, e.g. :9100")
+		api     = flag.String("api", "", "Serve the JSON control API at this address, e.g. 127.0.0.1:4067")
+		token   = flag.String("api-token", "", "Bearer token the control API requires (default: none)")
+		clay    = flag.String("claymore-api", "", "Serve Claymore's miner_getstat1 TCP API at this address, e.g. 127.0.0.1:3333")
+		restart = flag.Duration("max-restart", 500*time.Millisecond, "Cancel running solves when a new job has waited this long for them (0 = at once, <0 = never)")
+		evFile  = flag.String("events-file", "", "Append share, job and connection events to this file as JSON lines")
+		webhook = flag.String("webhook", "", "POST events as JSON to this URL, retrying failures")
+		onEvent = flag.String("on-event", "", "Run this shell command for each event, with the event as JSON on stdin")
+		hookEvs = flag.String("hook-events", "", "Events for -webhook and -on-event, e.g. share_rejected,disconnected (default: all)")
+		histOn  = flag.Bool("history", false, "Record shares and sessions for the history command")
+		histDB  = flag.String("history-db", defaultHistoryPath(), "File -history records to")
+		stopT   = flag.Duration("shutdown-timeout", 10*time.Second, "How long shutdown waits for running solves and share answers; a second signal exits at once")
+		pin     = flag.String("affinity", "off", "Pin solvers to CPUs: off, auto (per CCD/NUMA node) or CPU lists such as 0-7;8-15")
+
+		edgeBits  = flag.Int("edgebits", pkgsolver.DefaultParams.EdgeBits, "Cuckoo graph size (log2 edges) unless the job sets one")
+		proofSize = flag.Int("proofsize", pkgsolver.DefaultParams.ProofSize, "Cuckoo cycle length unless the job sets one")
+
+		idleOn       = flag.Bool("idle", false, "Back off while other processes keep the CPUs busy")
+		idleBusy     = flag.Float64("idle-busy", 0.25, "Share of all CPUs other processes use at which mining backs off")
+		idleResume   = flag.Float64("idle-resume", 0.10, "Share of all CPUs other processes use at which mining returns")
+		idleHold     = flag.Duration("idle-hold", 30*time.Second, "How long the load must stay past a threshold before switching")
+		idleThrottle = flag.Int("idle-throttle", 0, "Threads to keep mining with while the host is busy (0 = pause)")
+		mineHours    = flag.String("mine-hours", "", "Only mine in these local time windows, e.g. 22:00-07:00,12:00-13:00")
+
+		tempMax    = flag.Float64("temp-max", 0, "Throttle mining while the CPU is at or above this many °C (0 = off)")
+		tempResume = flag.Float64("temp-resume", 0, "Ease throttling at or below this many °C (default: -temp-max less 10)")
+		tempSteps  = flag.Int("temp-steps", 4, "Throttle levels between full speed and the lowest")
+		tempMode   = flag.String("temp-mode", "threads", "How to throttle: threads (fewer workers) or duty (pause part of the time)")
+		tempSensor = flag.String("temp-sensor", "", "CPU temp*_input file, or hwmon directory to search (default: /sys/class/hwmon)")
 	)
 	flag.Parse()
 
+	hugePages, err := pkgsolver.ParseHugePages(*huge)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	hookEvents, err := events.ParseTypes(*hookEvs)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	windows, err := idle.ParseWindows(*mineHours)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *tempMode != "threads" && *tempMode != "duty" {
+		fmt.Fprintln(os.Stderr, "-temp-mode must be threads or duty")
+		os.Exit(2)
+	}
+	thermalCfg := thermal.Config{High: *tempMax, Low: *tempResume, Steps: *tempSteps}
+	if thermalCfg.Low == 0 {
+		thermalCfg.Low = thermalCfg.High - 10
+	}
+	if thermalCfg.High > 0 && thermalCfg.Low >= thermalCfg.High {
+		fmt.Fprintln(os.Stderr, "-temp-resume must be below -temp-max")
+		os.Exit(2)
+	}
+	idleCfg := idle.Config{Windows: windows}
+	if *idleOn {
+		if *idleResume >= *idleBusy {
+			fmt.Fprintln(os.Stderr, "-idle-resume must be below -idle-busy")
+			os.Exit(2)
+		}
+		idleCfg.Busy, idleCfg.Idle, idleCfg.Hold = *idleBusy, *idleResume, *idleHold
+		idleCfg.Throttle = *idleThrottle > 0
+	}
+	if *tui && !term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprintln(os.Stderr, "-tui needs a terminal")
+		os.Exit(2)
+	}
+	var historyPath string
+	if *histOn {
+		if *histDB == "" {
+			fmt.Fprintln(os.Stderr, "-history needs -history-db: there is no config directory to default to")
+			os.Exit(2)
+		}
+		historyPath = *histDB
+	}
+
 	// Build pool address and username
 	poolAddr := fmt.Sprintf("%s:%s", POOL_HOST, POOL_PORT)
 	username := fmt.Sprintf("%s.%s", WALLET, *worker)
@@ -322,6 +975,10 @@ func main() {
 	fmt.Printf("Pool: %s\n", poolAddr)
 	fmt.Printf("Worker: %s\n", *worker)
 	fmt.Printf("Threads: %d\n", *threads)
+	fmt.Printf("Solver: %s\n", *solver)
+	fmt.Printf("Topology: %s\n", *topo)
+	fmt.Printf("Affinity: %s\n", *pin)
+	fmt.Printf("Huge pages: %s\n", hugePages)
 	fmt.Println("======================")
 	fmt.Println()
 
@@ -330,24 +987,95 @@ func main() {
 	if *debug {
 		config.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
 	}
-	logger, err := config.Build()
-	if err != nil {
+	var logger *zap.Logger
+	var pane *logPane // the dashboard's log, with -tui
+	if *tui {
+		logger, pane = newPaneLogger(config.Level)
+	} else if logger, err = config.Build(); err != nil {
 		panic(err)
 	}
 	defer logger.Sync()
+	pkgsolver.SetLogger(logger.Named("solver"))
 
 	// Create and start miner
-	miner := NewMiner(poolAddr, username, PASSWORD, *threads, logger)
+	miner := NewMiner(Config{
+		PoolAddr:     poolAddr,
+		Username:     username,
+		Password:     PASSWORD,
+		Threads:      *threads,
+		Backend:      *solver,
+		Params:       pkgsolver.Params{EdgeBits: *edgeBits, ProofSize: *proofSize},
+		Topology:     *topo,
+		Affinity:     *pin,
+		HugePages:    hugePages,
+		NoncesPerEN2: *en2,
+		SolveTime:    *solveT,
+		MaxRestart:   *restart,
+		MetricsAddr:  *metrics,
+		APIAddr:      *api,
+		APIToken:     *token,
+		ClaymoreAddr: *clay,
+
+		IdleScheduler: *idleOn || len(windows) > 0,
+		Idle:          idleCfg,
+		IdleThrottle:  *idleThrottle,
+
+		Thermal:       thermalCfg,
+		ThermalSensor: *tempSensor,
+		ThermalDuty:   *tempMode == "duty",
+
+		ShutdownTimeout: *stopT,
+
+		EventsFile: *evFile,
+		Webhook:    *webhook,
+		EventHook:  *onEvent,
+		HookEvents: hookEvents,
+
+		HistoryPath: historyPath,
+	}, logger)
 	if err := miner.Start(); err != nil {
+		if pane != nil {
+			pane.detach(true)
+		}
 		logger.Fatal("Failed to start miner", zap.Error(err))
 	}
 
-	// Wait for interrupt
+	// Wait for interrupt; control signals (SIGUSR1/SIGUSR2) pause and resume
 	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
-	<-sigCh
+	signal.Notify(sigCh, append(controlSignals, os.Interrupt, syscall.SIGTERM)...)
+	var dash *dashboard
+	if pane != nil {
+		// q in the dashboard stops the miner as an interrupt would
+		dash, err = startDashboard(miner, pane, config.Level, func() {
+			select {
+			case sigCh <- os.Interrupt:
+			default:
+			}
+		})
+		if err != nil {
+			pane.detach(true)
+			logger.Fatal("Failed to start dashboard", zap.Error(err))
+		}
+	}
+	for sig := range sigCh {
+		if !handleControlSignal(miner, sig) {
+			break
+		}
+	}
+	if dash != nil {
+		dash.stop()
+	}
 
-	// Shutdown
+	// Shutdown; a second interrupt skips the wait for running solves
+	if len(controlSignals) > 0 {
+		signal.Ignore(controlSignals...) // with no arguments it ignores all
+	}
+	go func() {
+		<-sigCh
+		logger.Warn("Forced exit")
+		miner.logSummary()
+		logger.Sync()
+		os.Exit(1)
+	}()
 	miner.Stop()
-	logger.Info("Miner stopped")
 }
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nitrogen/go-miner/pkg/history"
+	pkgsolver "github.com/nitrogen/go-miner/pkg/solver"
+	"github.com/nitrogen/go-miner/pkg/stratum"
+	"go.uber.org/zap"
+)
+
+// fakeBackend makes solvers whose Solve takes solveTime unless cancelled,
+// finding cycles made-up cycles per graph
+type fakeBackend struct {
+	solveTime time.Duration
+	cycles    int
+	found     atomic.Uint32 // made-up cycles, numbered to hash apart
+	created   atomic.Int32
+	closed    atomic.Int32
+	solving   atomic.Int32 // Solve calls in progress
+}
+
+func (b *fakeBackend) info() pkgsolver.BackendInfo {
+	return pkgsolver.BackendInfo{
+		Name: "fake",
+		New: func(params pkgsolver.Params, threads int) (pkgsolver.Backend, error) {
+			b.created.Add(1)
+			return &fakeSolver{backend: b, params: params, threads: threads}, nil
+		},
+		Memory: func(pkgsolver.Params, int) (uint64, error) { return mib, nil },
+	}
+}
+
+type fakeSolver struct {
+	backend *fakeBackend
+	params  pkgsolver.Params
+	threads int
+
+	mu     sync.Mutex
+	cancel chan struct{} // closed by Cancel; nil between solves
+	graphs int
+}
+
+func (s *fakeSolver) SetHeader([]byte) error { return nil }
+
+func (s *fakeSolver) Solve(baseNonce, nonceRange uint32) ([]pkgsolver.Solution, error) {
+	return s.SolveContext(context.Background(), baseNonce, nonceRange)
+}
+
+func (s *fakeSolver) SolveContext(ctx context.Context, baseNonce, nonceRange uint32) ([]pkgsolver.Solution, error) {
+	s.backend.solving.Add(1)
+	defer s.backend.solving.Add(-1)
+	cancel := make(chan struct{})
+	s.mu.Lock()
+	s.cancel = cancel
+	s.mu.Unlock()
+
+	graphs := int(nonceRange)
+	select {
+	case <-time.After(s.backend.solveTime):
+	case <-cancel:
+		graphs = 0
+	case <-ctx.Done():
+		graphs = 0
+	}
+	s.mu.Lock()
+	s.cancel, s.graphs = nil, graphs
+	s.mu.Unlock()
+	var sols []pkgsolver.Solution
+	for range s.backend.cycles * graphs {
+		sols = append(sols, pkgsolver.Solution{Nonce: []uint32{s.backend.found.Add(1)}})
+	}
+	return sols, nil
+}
+
+func (s *fakeSolver) Cancel() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		close(s.cancel)
+		s.cancel = nil
+	}
+}
+
+func (s *fakeSolver) Close() { s.backend.closed.Add(1) }
+
+func (s *fakeSolver) Capabilities() pkgsolver.Capabilities {
+	return pkgsolver.Capabilities{Backend: "fake", Params: s.params, Threads: s.threads}
+}
+
+func (s *fakeSolver) LastGraphs() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.graphs
+}
+
+// newTestMiner returns a miner laid out as topo on backend, as Start would
+// leave it before the first job, and its pool client
+func newTestMiner(t *testing.T, backend *fakeBackend, topo Topology, maxRestart time.Duration) (*Miner, *stratum.Client) {
+	m := NewMiner(Config{
+		Params:          pkgsolver.Params{EdgeBits: 19, ProofSize: 42},
+		Affinity:        "off",
+		NoncesPerEN2:    defaultNoncesPerEN2,
+		MaxRestart:      maxRestart,
+		ShutdownTimeout: 5 * time.Second,
+	}, zap.NewNop())
+	m.solverBE = backend.info()
+	m.topology, m.layout = topo, topo
+	m.threads = topo.Threads()
+	m.solvers = make([]pkgsolver.Backend, topo.Solvers)
+	now := time.Now()
+	for range topo.Solvers {
+		m.batchers = append(m.batchers, newBatcher(m.cfg.SolveTime))
+		m.stats.Workers = append(m.stats.Workers, newWorkerStats(now))
+	}
+	c := stratum.NewClient("127.0.0.1:1", "user", "x", m.logger)
+	m.client.Store(c)
+	t.Cleanup(m.Stop)
+	return m, c
+}
+
+func testWork(jobID string) *stratum.Work {
+	return &stratum.Work{JobID: jobID, ExtraNonce2Size: 4, CleanJobs: true}
+}
+
+// waitFor polls cond for up to five seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRestartAbort(t *testing.T) {
+	for _, c := range []struct {
+		name       string
+		solveTime  time.Duration
+		maxRestart time.Duration
+		aborts     uint64
+	}{
+		{"solves finish in time", 5 * time.Millisecond, 5 * time.Second, 0},
+		{"solves cancelled", time.Minute, 20 * time.Millisecond, 1},
+		{"waits for the solves", 50 * time.Millisecond, -1, 0},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			backend := &fakeBackend{solveTime: c.solveTime}
+			m, client := newTestMiner(t, backend, Topology{2, 1}, c.maxRestart)
+			m.handleNewWork(client, testWork("j1"))
+			waitFor(t, "solves to start", func() bool { return backend.solving.Load() > 0 })
+
+			start := time.Now()
+			m.handleNewWork(client, testWork("j2"))
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("restart took %v", elapsed)
+			}
+			if got := m.stats.RestartAborts.Load(); got != c.aborts {
+				t.Errorf("RestartAborts = %d, want %d", got, c.aborts)
+			}
+			if !m.mining.Load() {
+				t.Error("workers not restarted on the new job")
+			}
+		})
+	}
+}
+
+// startBackend is registered once as "fake-start" for TestStartFailure
+var (
+	startBackend     = &fakeBackend{solveTime: time.Millisecond}
+	registerStartBEs sync.Once
+)
+
+// freeAddr returns a local address nothing listens on
+func freeAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+	return ln.Addr().String()
+}
+
+func TestStartFailure(t *testing.T) {
+	registerStartBEs.Do(func() {
+		info := startBackend.info()
+		info.Name = "fake-start"
+		pkgsolver.RegisterBackend(info)
+	})
+	created, closed := startBackend.created.Load(), startBackend.closed.Load()
+	cfg := Config{
+		PoolAddr:        freeAddr(t), // refuses the connection
+		Backend:         "fake-start",
+		Params:          pkgsolver.Params{EdgeBits: 19, ProofSize: 42},
+		Topology:        "2x1",
+		Affinity:        "off",
+		NoncesPerEN2:    defaultNoncesPerEN2,
+		MetricsAddr:     freeAddr(t),
+		APIAddr:         freeAddr(t),
+		ClaymoreAddr:    freeAddr(t),
+		EventsFile:      filepath.Join(t.TempDir(), "events.jsonl"),
+		HistoryPath:     filepath.Join(t.TempDir(), "history.db"),
+		ShutdownTimeout: 5 * time.Second,
+	}
+	m := NewMiner(cfg, zap.NewNop())
+	if err := m.Start(); err == nil {
+		t.Fatal("Start with no pool: expected error")
+	}
+
+	if got := startBackend.created.Load() - created; got != 2 {
+		t.Errorf("%d solvers created, want 2", got)
+	}
+	if got := startBackend.closed.Load() - closed; got != 2 {
+		t.Errorf("%d solvers closed, want 2", got)
+	}
+	for _, addr := range []string{cfg.MetricsAddr, cfg.APIAddr, cfg.ClaymoreAddr} {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			t.Errorf("%s still in use: %v", addr, err)
+			continue
+		}
+		ln.Close()
+	}
+	// Nor is the history file left locked
+	store, err := history.OpenReadOnly(cfg.HistoryPath)
+	if err != nil {
+		t.Fatalf("history left open: %v", err)
+	}
+	store.Close()
+}
+
+// silentPool is a pool that subscribes and authorizes a miner, then never
+// answers its shares
+func silentPool(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		dec := json.NewDecoder(conn)
+		for {
+			var req stratum.Request
+			if err := dec.Decode(&req); err != nil {
+				return
+			}
+			switch req.Method {
+			case "mining.subscribe":
+				fmt.Fprintf(conn, `{"id":%d,"result":[[],"00",4],"error":null}`+"\n", req.ID)
+			case "mining.authorize":
+				fmt.Fprintf(conn, `{"id":%d,"result":true,"error":null}`+"\n", req.ID)
+			}
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestSubmitDoesNotDelayRestart(t *testing.T) {
+	backend := &fakeBackend{solveTime: time.Millisecond, cycles: 8}
+	m, _ := newTestMiner(t, backend, Topology{2, 1}, 20*time.Millisecond)
+	m.cfg.ShutdownTimeout = 100 * time.Millisecond // the shares are never answered
+	c := m.newClient(silentPool(t), "user", "x")
+	if err := c.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	m.client.Store(c)
+
+	// Without a pool difficulty, about half the cycles meet the nBits target
+	work := testWork("j1")
+	work.NBits = "207fffff"
+	m.handleNewWork(c, work)
+	waitFor(t, "a share to be submitted", func() bool { return m.submitting.Load() > 0 })
+
+	switched := make(chan struct{})
+	go func() {
+		m.handleNewWork(c, testWork("j2"))
+		close(switched)
+	}()
+	select {
+	case <-switched:
+	case <-time.After(time.Second):
+		t.Fatal("job switch waited for a share's answer")
+	}
+	if !m.mining.Load() {
+		t.Error("workers not restarted on the new job")
+	}
+}
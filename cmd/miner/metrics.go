@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nitrogen/go-miner/pkg/metrics"
+	"github.com/nitrogen/go-miner/pkg/stats"
+	"github.com/nitrogen/go-miner/pkg/stratum"
+	"go.uber.org/zap"
+)
+
+// serveMetrics starts serving /metrics on addr in the background
+func (m *Miner) serveMetrics(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("metrics: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler(m.collectMetrics))
+	m.metrics = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	m.logger.Info("Serving metrics", zap.String("addr", ln.Addr().String()))
+	go func() {
+		if err := m.metrics.Serve(ln); err != nil && err != http.ErrServerClosed {
+			m.logger.Error("Metrics server failed", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+// collectMetrics writes the miner's and the stratum client's metrics
+func (m *Miner) collectMetrics(w *metrics.Writer) {
+	now := time.Now()
+
+	w.Describe("miner_uptime_seconds", metrics.Gauge, "Seconds since the miner started")
+	w.Sample("miner_uptime_seconds", now.Sub(m.stats.StartTime).Seconds())
+
+	w.Describe("miner_graphs_total", metrics.Counter, "Graphs the solvers searched to the end")
+	w.Sample("miner_graphs_total", float64(m.stats.Graphs.Total()))
+	w.Describe("miner_graphs_per_second", metrics.Gauge, "Graphs searched per second, averaged over window")
+	writeRates(w, "miner_graphs_per_second", m.stats.Graphs.Rates())
+
+	w.Describe("miner_solutions_total", metrics.Counter, "Cycles found in searched graphs")
+	w.Sample("miner_solutions_total", float64(m.stats.Cycles.Total()))
+
+	w.Describe("miner_shares_total", metrics.Counter, "Shares submitted, by outcome and reject reason")
+	w.Sample("miner_shares_total", float64(m.stats.Shares.Total()), "outcome", "accepted", "reason", "")
+	reasons := m.stats.RejectReasons.Snapshot()
+	for _, r := range sortedKeys(reasons) {
+		w.Sample("miner_shares_total", float64(reasons[r]), "outcome", "rejected", "reason", r)
+	}
+
+	w.Describe("miner_restart_latency_seconds", metrics.Histogram, "Time from a job's arrival to each worker solving it")
+	w.Histogram("miner_restart_latency_seconds", m.stats.RestartLatency.Snapshot())
+	w.Describe("miner_restart_aborts_total", metrics.Counter, "Job switches that cancelled running solves")
+	w.Sample("miner_restart_aborts_total", float64(m.stats.RestartAborts.Load()))
+
+	w.Describe("miner_solve_duration_seconds", metrics.Histogram, "Wall-clock time of each Solve call, by worker")
+	m.solversMu.Lock()
+	workers := m.stats.Workers
+	m.solversMu.Unlock()
+	for i, ws := range workers {
+		w.Histogram("miner_solve_duration_seconds", ws.SolveDuration.Snapshot(), "worker", strconv.Itoa(i))
+	}
+
+	w.Describe("miner_events_dropped_total", metrics.Counter, "Events event sinks missed because they fell behind")
+	w.Sample("miner_events_dropped_total", float64(m.events.Dropped()))
+
+	if temp, ok := m.cpuTemp(); ok {
+		w.Describe("miner_cpu_temperature_celsius", metrics.Gauge, "Last CPU temperature reading")
+		w.Sample("miner_cpu_temperature_celsius", temp)
+		w.Describe("miner_thermal_level", metrics.Gauge, "Thermal throttle level, 0 when not throttling")
+		w.Sample("miner_thermal_level", float64(m.stats.ThermalLevel.Load()))
+	}
+
+	m.collectStratum(w, m.client.Load(), now)
+}
+
+// collectStratum writes the pool connection's metrics
+func (m *Miner) collectStratum(w *metrics.Writer, c *stratum.Client, now time.Time) {
+	w.Describe("stratum_connected", metrics.Gauge, "Whether the pool connection is up")
+	connected := 0.0
+	if c.Connected() {
+		connected = 1
+	}
+	w.Sample("stratum_connected", connected)
+
+	w.Describe("stratum_reconnects_total", metrics.Counter, "Reconnections to the pool")
+	w.Sample("stratum_reconnects_total", float64(c.Reconnects()))
+
+	w.Describe("stratum_difficulty", metrics.Gauge, "Current pool difficulty")
+	w.Sample("stratum_difficulty", c.GetDifficulty())
+
+	if t := c.LastJobTime(); !t.IsZero() {
+		w.Describe("stratum_job_age_seconds", metrics.Gauge, "Seconds since the current job arrived")
+		w.Sample("stratum_job_age_seconds", now.Sub(t).Seconds())
+	}
+
+	w.Describe("stratum_rpc_duration_seconds", metrics.Histogram, "Round-trip time of answered calls, by method")
+	latency := c.RPCLatency()
+	for _, method := range sortedKeys(latency) {
+		w.Histogram("stratum_rpc_duration_seconds", latency[method], "method", method)
+	}
+}
+
+// writeRates writes 1m/5m/15m rates as one sample per window
+func writeRates(w *metrics.Writer, name string, rates [len(stats.Windows)]float64) {
+	for i, win := range stats.Windows {
+		w.Sample(name, rates[i], "window", fmt.Sprintf("%dm", int(win.Minutes())))
+	}
+}
+
+// rejectReason is the label a failed submission is counted under. Pools
+// word their messages freely, so they are sorted into a fixed set to keep
+// the metric's label values bounded; events and history keep the message.
+func rejectReason(err error) string {
+	var rej *stratum.RejectedError
+	if !errors.As(err, &rej) {
+		return "other"
+	}
+	msg := strings.ToLower(rej.Reason)
+	switch {
+	case strings.Contains(msg, "duplicate"):
+		return "duplicate"
+	case strings.Contains(msg, "stale"), strings.Contains(msg, "job not found"),
+		strings.Contains(msg, "unknown job"), strings.Contains(msg, "invalid job"):
+		return "stale"
+	case strings.Contains(msg, "low difficulty"), strings.Contains(msg, "low diff"),
+		strings.Contains(msg, "above target"), strings.Contains(msg, "high-hash"):
+		return "low-difficulty"
+	}
+	return "other"
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
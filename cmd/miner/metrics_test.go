@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/nitrogen/go-miner/pkg/stratum"
+)
+
+func TestRejectReason(t *testing.T) {
+	rejected := func(msg string) error { return &stratum.RejectedError{Reason: msg} }
+	for _, c := range []struct {
+		err  error
+		want string
+	}{
+		{rejected("Duplicate share"), "duplicate"},
+		{rejected("Stale share"), "stale"},
+		{rejected("Job not found"), "stale"},
+		{rejected("Invalid job id"), "stale"},
+		{rejected("Low difficulty share"), "low-difficulty"},
+		{rejected("Share above target"), "low-difficulty"},
+		{rejected("high-hash"), "low-difficulty"},
+		{rejected("rejected"), "other"},
+		{rejected("worker 7f3a banned until 12:00"), "other"},
+		{fmt.Errorf("submit: %w", rejected("duplicate")), "duplicate"},
+		{errors.New("i/o timeout"), "other"},
+	} {
+		if got := rejectReason(c.err); got != c.want {
+			t.Errorf("rejectReason(%v) = %q, want %q", c.err, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nitrogen/go-miner/pkg/sysinfo"
+)
+
+// Placement is where one solver runs: the CPUs its threads may use and the
+// NUMA node its memory comes from (-1 for no preference)
+type Placement struct {
+	CPUs []int
+	Node int
+}
+
+func (p Placement) String() string {
+	return fmt.Sprintf("cpus %s node %d", formatCPUList(p.CPUs), p.Node)
+}
+
+// resolvePlacement turns the -affinity spec into one Placement per solver,
+// or nil when solvers are left to the scheduler:
+//
+//   - "" or "off": no pinning;
+//   - "auto": solvers are spread over the L3 cache domains (CCDs), or NUMA
+//     nodes if the caches are unknown, and split each domain's CPUs;
+//   - "0-7;8-15": explicit CPU lists, assigned to solvers round-robin.
+func resolvePlacement(spec string, topo Topology, l3 []sysinfo.Cache, nodes []sysinfo.Node) ([]Placement, error) {
+	var sets [][]int
+	switch spec = strings.TrimSpace(spec); spec {
+	case "", "off":
+		return nil, nil
+	case "auto":
+		sets = autoPlacement(topo, cpuDomains(l3, nodes))
+		if sets == nil {
+			return nil, nil
+		}
+	default:
+		for _, f := range strings.Split(spec, ";") {
+			cpus, err := sysinfo.ParseCPUList(f)
+			if err != nil || len(cpus) == 0 {
+				return nil, fmt.Errorf("bad affinity %q: want auto, off or CPU lists such as 0-7;8-15", spec)
+			}
+			sets = append(sets, cpus)
+		}
+	}
+
+	placements := make([]Placement, topo.Solvers)
+	for i := range placements {
+		cpus := sets[i%len(sets)]
+		placements[i] = Placement{CPUs: cpus, Node: sysinfo.NodeOf(nodes, cpus)}
+	}
+	return placements, nil
+}
+
+// cpuDomains groups the CPUs by shared L3, falling back to NUMA nodes
+func cpuDomains(l3 []sysinfo.Cache, nodes []sysinfo.Node) [][]int {
+	var domains [][]int
+	for _, c := range l3 {
+		domains = append(domains, c.CPUs)
+	}
+	if len(domains) == 0 {
+		for _, n := range nodes {
+			domains = append(domains, n.CPUs)
+		}
+	}
+	return domains
+}
+
+// autoPlacement deals solvers out to domains round-robin and splits each
+// domain's CPUs evenly between the solvers it got. Solvers with more threads
+// than the smallest domain has CPUs share all CPUs instead.
+func autoPlacement(topo Topology, domains [][]int) [][]int {
+	if len(domains) == 0 {
+		return nil
+	}
+	smallest := len(domains[0])
+	for _, d := range domains {
+		smallest = min(smallest, len(d))
+	}
+	if topo.ThreadsPerSolver > smallest {
+		var all []int
+		for _, d := range domains {
+			all = append(all, d...)
+		}
+		domains = [][]int{all}
+	}
+
+	perDomain := make([]int, len(domains))
+	for i := 0; i < topo.Solvers; i++ {
+		perDomain[i%len(domains)]++
+	}
+	sets := make([][]int, topo.Solvers)
+	for i := range sets {
+		d := domains[i%len(domains)]
+		k := perDomain[i%len(domains)]
+		if k > len(d) {
+			sets[i] = d
+			continue
+		}
+		// i/len(domains) is this solver's slot among those on domain d
+		chunk := len(d) / k
+		slot := i / len(domains)
+		sets[i] = d[slot*chunk : (slot+1)*chunk]
+	}
+	return sets
+}
+
+// formatCPUList renders cpus in the kernel's list format, e.g. "0-3,8"
+func formatCPUList(cpus []int) string {
+	var b strings.Builder
+	for i := 0; i < len(cpus); {
+		j := i
+		for j+1 < len(cpus) && cpus[j+1] == cpus[j]+1 {
+			j++
+		}
+		if b.Len() > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Itoa(cpus[i]))
+		if j > i {
+			b.WriteByte('-')
+			b.WriteString(strconv.Itoa(cpus[j]))
+		}
+		i = j + 1
+	}
+	return b.String()
+}
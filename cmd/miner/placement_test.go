@@ -0,0 +1,97 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nitrogen/go-miner/pkg/sysinfo"
+)
+
+func cpuRange(lo, hi int) []int {
+	var cpus []int
+	for c := lo; c <= hi; c++ {
+		cpus = append(cpus, c)
+	}
+	return cpus
+}
+
+func TestAutoPlacement(t *testing.T) {
+	// Two CCDs of eight CPUs
+	ccds := [][]int{cpuRange(0, 7), cpuRange(8, 15)}
+	for _, c := range []struct {
+		name    string
+		topo    Topology
+		domains [][]int
+		want    [][]int
+	}{
+		{"one solver per domain", Topology{2, 8}, ccds, [][]int{cpuRange(0, 7), cpuRange(8, 15)}},
+		{"solvers split domains", Topology{4, 4}, ccds,
+			[][]int{cpuRange(0, 3), cpuRange(8, 11), cpuRange(4, 7), cpuRange(12, 15)}},
+		{"uneven deal", Topology{3, 2}, ccds, [][]int{cpuRange(0, 3), cpuRange(8, 15), cpuRange(4, 7)}},
+		{"wider than a domain", Topology{1, 12}, ccds, [][]int{cpuRange(0, 15)}},
+		{"more solvers than CPUs", Topology{3, 1}, [][]int{{0, 1}}, [][]int{{0, 1}, {0, 1}, {0, 1}}},
+		{"no domains", Topology{2, 2}, nil, nil},
+	} {
+		if got := autoPlacement(c.topo, c.domains); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: autoPlacement(%v) = %v, want %v", c.name, c.topo, got, c.want)
+		}
+	}
+}
+
+func TestResolvePlacement(t *testing.T) {
+	nodes := []sysinfo.Node{{ID: 0, CPUs: cpuRange(0, 7)}, {ID: 1, CPUs: cpuRange(8, 15)}}
+	l3 := []sysinfo.Cache{
+		{Level: 3, Size: 32 * mib, CPUs: cpuRange(0, 3)},
+		{Level: 3, Size: 32 * mib, CPUs: cpuRange(4, 7)},
+		{Level: 3, Size: 32 * mib, CPUs: cpuRange(8, 11)},
+		{Level: 3, Size: 32 * mib, CPUs: cpuRange(12, 15)},
+	}
+	for _, c := range []struct {
+		name  string
+		spec  string
+		topo  Topology
+		l3    []sysinfo.Cache
+		nodes []sysinfo.Node
+		want  []Placement
+	}{
+		{"off", "off", Topology{4, 4}, l3, nodes, nil},
+		{"empty", "", Topology{4, 4}, l3, nodes, nil},
+		{"auto by L3", "auto", Topology{4, 4}, l3, nodes, []Placement{
+			{cpuRange(0, 3), 0}, {cpuRange(4, 7), 0}, {cpuRange(8, 11), 1}, {cpuRange(12, 15), 1},
+		}},
+		{"auto by node without caches", "auto", Topology{2, 8}, nil, nodes, []Placement{
+			{cpuRange(0, 7), 0}, {cpuRange(8, 15), 1},
+		}},
+		{"auto, layout unknown", "auto", Topology{2, 8}, nil, nil, nil},
+		{"explicit lists round-robin", "0-3; 8-11", Topology{3, 4}, l3, nodes, []Placement{
+			{cpuRange(0, 3), 0}, {cpuRange(8, 11), 1}, {cpuRange(0, 3), 0},
+		}},
+		{"list across nodes", "6-9", Topology{1, 4}, l3, nodes, []Placement{{cpuRange(6, 9), -1}}},
+	} {
+		got, err := resolvePlacement(c.spec, c.topo, c.l3, c.nodes)
+		if err != nil || !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: resolvePlacement(%q, %v) = %v, %v; want %v", c.name, c.spec, c.topo, got, err, c.want)
+		}
+	}
+	for _, bad := range []string{"0-3;", "x", "3-1"} {
+		if _, err := resolvePlacement(bad, Topology{1, 1}, l3, nodes); err == nil {
+			t.Errorf("resolvePlacement(%q): expected error", bad)
+		}
+	}
+}
+
+func TestFormatCPUList(t *testing.T) {
+	for _, c := range []struct {
+		cpus []int
+		want string
+	}{
+		{nil, ""},
+		{[]int{5}, "5"},
+		{cpuRange(0, 3), "0-3"},
+		{[]int{0, 1, 2, 3, 8, 10, 11}, "0-3,8,10-11"},
+	} {
+		if got := formatCPUList(c.cpus); got != c.want {
+			t.Errorf("formatCPUList(%v) = %q, want %q", c.cpus, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package main
+
+// resetPeakRSS is a no-op where the peak cannot be reset
+func resetPeakRSS() {}
+
+// peakRSS is unknown outside Linux
+func peakRSS() uint64 {
+	return 0
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// controlSignals is empty: there are no user signals on this platform
+var controlSignals []os.Signal
+
+func handleControlSignal(*Miner, os.Signal) bool {
+	return false
+}
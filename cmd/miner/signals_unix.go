@@ -0,0 +1,24 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// controlSignals pause (SIGUSR1) and resume (SIGUSR2) mining
+var controlSignals = []os.Signal{syscall.SIGUSR1, syscall.SIGUSR2}
+
+// handleControlSignal acts on a control signal, reporting whether sig was one
+func handleControlSignal(m *Miner, sig os.Signal) bool {
+	switch sig {
+	case syscall.SIGUSR1:
+		m.Pause()
+	case syscall.SIGUSR2:
+		m.Resume()
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"math"
+	"os"
+	"time"
+
+	"github.com/nitrogen/go-miner/pkg/thermal"
+	"go.uber.org/zap"
+)
+
+const (
+	// thermalInterval is how often the CPU temperature is read
+	thermalInterval = 5 * time.Second
+	// dutyPeriod is one on/off cycle when throttling by duty cycle
+	dutyPeriod = 20 * time.Second
+)
+
+// holdThermal is the pause hold and thread cap reason of thermal throttling
+const holdThermal = "thermal"
+
+// thermalSensor resolves ThermalSensor: a temp*_input file as is, or a
+// directory laid out like /sys/class/hwmon (the default) to search
+func (m *Miner) thermalSensor() (string, error) {
+	path := m.cfg.ThermalSensor
+	if path == "" {
+		path = thermal.HwmonRoot
+	}
+	if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+		return path, nil
+	}
+	return thermal.FindSensor(path)
+}
+
+// runThermal throttles mining while the CPU runs hot, until the miner
+// stops: by capping threads, or with ThermalDuty by pausing for part of
+// each dutyPeriod. The temperature is read every thermalInterval while
+// mining, but not in a duty cycle's off phase, whose cooling would hide how
+// hot mining runs.
+func (m *Miner) runThermal(sensor string) {
+	ctl := thermal.NewController(m.cfg.Thermal)
+	level := 0
+	off := false
+	var phaseEnd time.Time // of the duty cycle's on or off phase
+	for {
+		if !off {
+			level = m.readThermal(sensor, ctl, level)
+		}
+		wait := thermalInterval
+		if m.cfg.ThermalDuty && level > 0 {
+			now := time.Now()
+			if !now.Before(phaseEnd) {
+				on := time.Duration(float64(dutyPeriod) * ctl.Fraction())
+				if off || phaseEnd.IsZero() {
+					off, phaseEnd = false, now.Add(on)
+				} else {
+					off, phaseEnd = true, now.Add(dutyPeriod-on)
+				}
+			}
+			if off {
+				wait = phaseEnd.Sub(now)
+			} else {
+				wait = min(wait, phaseEnd.Sub(now))
+			}
+		} else {
+			off, phaseEnd = false, time.Time{}
+		}
+		m.setHold(holdThermal, off)
+		if !m.sleep(wait) {
+			return
+		}
+	}
+}
+
+// readThermal reads the temperature into the stats and ctl, returning the
+// throttle level; without ThermalDuty it also applies the level as a cap
+func (m *Miner) readThermal(sensor string, ctl *thermal.Controller, level int) int {
+	temp, err := thermal.ReadTemp(sensor)
+	if err != nil {
+		m.logger.Warn("Failed to read CPU temperature", zap.String("sensor", sensor), zap.Error(err))
+		return level
+	}
+	m.stats.CPUTemp.Store(math.Float64bits(temp))
+	next := ctl.Update(temp)
+	if next == level {
+		return level
+	}
+	m.logger.Info("Thermal throttle",
+		zap.Int("from", level), zap.Int("to", next),
+		zap.Float64("tempC", temp), zap.Float64("fraction", ctl.Fraction()))
+	m.stats.ThermalLevel.Store(int32(next))
+	if !m.cfg.ThermalDuty {
+		m.capThermal(ctl.Fraction(), next)
+	}
+	return next
+}
+
+// capThermal caps the threads at fraction of those that would run without
+// it: the live layout under the other caps, which may differ from the
+// threads asked for
+func (m *Miner) capThermal(fraction float64, level int) {
+	n := 0
+	if level > 0 {
+		m.solversMu.Lock()
+		uncapped := m.threads
+		for reason, c := range m.caps {
+			if reason != holdThermal {
+				uncapped = min(uncapped, c)
+			}
+		}
+		running := resizeTopology(m.layout, uncapped, m.solverBE.MultiThreaded).Threads()
+		m.solversMu.Unlock()
+		n = max(1, int(math.Round(float64(running)*fraction)))
+	}
+	if err := m.setThreadCap(holdThermal, n); err != nil {
+		m.logger.Warn("Failed to throttle", zap.Error(err))
+	}
+}
+
+// cpuTemp returns the last CPU temperature in degrees Celsius, if any
+func (m *Miner) cpuTemp() (float64, bool) {
+	temp := math.Float64frombits(m.stats.CPUTemp.Load())
+	return temp, !math.IsNaN(temp)
+}
+
+// sleep waits for d, returning false if the miner stops first
+func (m *Miner) sleep(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-m.stopCh:
+		return false
+	}
+}
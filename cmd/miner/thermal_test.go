@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCapThermal(t *testing.T) {
+	backend := &fakeBackend{solveTime: time.Millisecond}
+	m, _ := newTestMiner(t, backend, Topology{4, 2}, time.Second)
+	for _, c := range []struct {
+		threads  int
+		idleCap  int
+		fraction float64
+		want     int
+	}{
+		{8, 0, 0.5, 4},
+		{7, 0, 0.5, 3}, // 7 threads run as 3x2
+		{8, 4, 0.5, 2}, // under the idle cap
+		{8, 0, 0.01, 1},
+	} {
+		m.SetThreads(c.threads)
+		m.setThreadCap(holdIdle, c.idleCap)
+		m.capThermal(c.fraction, 1)
+		if got := m.Status().ThreadCaps[holdThermal]; got != c.want {
+			t.Errorf("%d threads, idle cap %d: thermal cap at %v = %d, want %d", c.threads, c.idleCap, c.fraction, got, c.want)
+		}
+		m.capThermal(1, 0)
+		if _, ok := m.Status().ThreadCaps[holdThermal]; ok {
+			t.Error("thermal cap not lifted at level 0")
+		}
+	}
+}
+
+func TestCPUTempUnknown(t *testing.T) {
+	m, _ := newTestMiner(t, &fakeBackend{}, Topology{1, 1}, time.Second)
+	if _, ok := m.cpuTemp(); ok {
+		t.Error("cpuTemp known before any reading")
+	}
+	b, err := json.Marshal(m.Status())
+	if err != nil || strings.Contains(string(b), "cpu_temp_c") {
+		t.Errorf("Status without a reading = %s, %v", b, err)
+	}
+
+	// Zero degrees is a reading like any other
+	m.stats.CPUTemp.Store(math.Float64bits(0))
+	if st := m.Status(); st.CPUTemp == nil || *st.CPUTemp != 0 {
+		t.Errorf("Status().CPUTemp = %v, want 0", st.CPUTemp)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	pkgsolver "github.com/nitrogen/go-miner/pkg/solver"
+	"github.com/nitrogen/go-miner/pkg/sysinfo"
+)
+
+// Topology is how the miner's threads are split into solvers: Solvers
+// graphs in flight at once, each trimmed by ThreadsPerSolver threads. More
+// solvers means more memory; more threads per solver means each graph is
+// done sooner.
+type Topology struct {
+	Solvers          int
+	ThreadsPerSolver int
+}
+
+func (t Topology) String() string {
+	return fmt.Sprintf("%dx%d", t.Solvers, t.ThreadsPerSolver)
+}
+
+// Threads is the total number of solver threads
+func (t Topology) Threads() int {
+	return t.Solvers * t.ThreadsPerSolver
+}
+
+// parseTopology parses "SxT". It returns auto for "auto" or an empty spec.
+func parseTopology(spec string) (t Topology, auto bool, err error) {
+	spec = strings.TrimSpace(strings.ToLower(spec))
+	if spec == "" || spec == "auto" {
+		return Topology{}, true, nil
+	}
+	s, th, ok := strings.Cut(spec, "x")
+	if ok {
+		t.Solvers, err = strconv.Atoi(s)
+		if err == nil {
+			t.ThreadsPerSolver, err = strconv.Atoi(th)
+		}
+	}
+	if !ok || err != nil || t.Solvers < 1 || t.ThreadsPerSolver < 1 {
+		return Topology{}, false, fmt.Errorf("bad topology %q: want SOLVERSxTHREADS (e.g. 4x8) or auto", spec)
+	}
+	return t, false, nil
+}
+
+// resolveTopology turns the -topology spec into a Topology. An explicit
+// SxT is used as given; auto is derived from the backend, the L3 cache size
+// and available memory.
+func resolveTopology(spec string, info pkgsolver.BackendInfo, params pkgsolver.Params, threads int, l3 uint64, mem sysinfo.Memory) (Topology, error) {
+	t, auto, err := parseTopology(spec)
+	if err != nil || !auto {
+		return t, err
+	}
+	return autoTopology(info, params, threads, l3, mem)
+}
+
+// autoTopology picks a topology for threads:
+//
+//   - multi-threaded backends (mean) trim one graph with all threads;
+//   - otherwise every thread gets its own graph, unless those graphs would
+//     spill out of an L3 cache a single one fits in, in which case threads
+//     are grouped so the working sets stay cache resident;
+//   - either way, the solvers must fit in 90% of available memory.
+//
+// The solver count is rounded down to divide threads evenly.
+func autoTopology(info pkgsolver.BackendInfo, params pkgsolver.Params, threads int, l3 uint64, mem sysinfo.Memory) (Topology, error) {
+	if threads < 1 {
+		return Topology{}, fmt.Errorf("invalid thread count %d", threads)
+	}
+	if info.MultiThreaded {
+		return Topology{Solvers: 1, ThreadsPerSolver: threads}, nil
+	}
+
+	perGraph, err := info.Memory(params, 1)
+	if err != nil {
+		return Topology{}, err
+	}
+	solvers := threads
+	if l3 > 0 && perGraph <= l3 && perGraph*uint64(threads) > l3 {
+		solvers = max(1, int(l3/perGraph))
+	}
+	if budget := mem.Available / 10 * 9; budget > 0 {
+		for solvers > 1 && perGraph*uint64(solvers) > budget {
+			solvers--
+		}
+	}
+	for threads%solvers != 0 {
+		solvers--
+	}
+	return Topology{Solvers: solvers, ThreadsPerSolver: threads / solvers}, nil
+}
+
+// resizeTopology lays out n threads the way t is laid out: a single solver
+// of a multi-threaded backend gets all n threads, otherwise solvers of t's
+// width are added or removed. Fewer threads than that width leave one
+// solver of n threads.
+func resizeTopology(t Topology, n int, multiThreaded bool) Topology {
+	if (multiThreaded && t.Solvers == 1) || n < t.ThreadsPerSolver {
+		return Topology{Solvers: 1, ThreadsPerSolver: n}
+	}
+	return Topology{Solvers: n / t.ThreadsPerSolver, ThreadsPerSolver: t.ThreadsPerSolver}
+}
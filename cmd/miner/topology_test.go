@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	pkgsolver "github.com/nitrogen/go-miner/pkg/solver"
+	"github.com/nitrogen/go-miner/pkg/sysinfo"
+)
+
+const mib = 1 << 20
+
+func TestParseTopology(t *testing.T) {
+	for _, c := range []struct {
+		spec string
+		want Topology
+		auto bool
+	}{
+		{"4x8", Topology{4, 8}, false},
+		{" 2X3 ", Topology{2, 3}, false},
+		{"1x1", Topology{1, 1}, false},
+		{"auto", Topology{}, true},
+		{"", Topology{}, true},
+	} {
+		got, auto, err := parseTopology(c.spec)
+		if err != nil || got != c.want || auto != c.auto {
+			t.Errorf("parseTopology(%q) = %v, %v, %v; want %v, %v", c.spec, got, auto, err, c.want, c.auto)
+		}
+	}
+	for _, bad := range []string{"4", "0x8", "4x0", "4x", "x4", "ax2", "4x-1", "2x2x2"} {
+		if _, _, err := parseTopology(bad); err == nil {
+			t.Errorf("parseTopology(%q): expected error", bad)
+		}
+	}
+}
+
+// fakeBackendInfo describes a backend whose graphs need perGraph bytes
+func fakeBackendInfo(perGraph uint64, multiThreaded bool) pkgsolver.BackendInfo {
+	return pkgsolver.BackendInfo{
+		Name:          "fake",
+		MultiThreaded: multiThreaded,
+		Memory: func(pkgsolver.Params, int) (uint64, error) {
+			return perGraph, nil
+		},
+	}
+}
+
+func TestAutoTopology(t *testing.T) {
+	params := pkgsolver.Params{EdgeBits: 19, ProofSize: 42}
+	for _, c := range []struct {
+		name     string
+		info     pkgsolver.BackendInfo
+		threads  int
+		l3       uint64
+		mem      sysinfo.Memory
+		want     Topology
+		wantFail bool
+	}{
+		{"mean takes all threads", fakeBackendInfo(4<<30, true), 16, 32 * mib, sysinfo.Memory{}, Topology{1, 16}, false},
+		{"lean, nothing known", fakeBackendInfo(mib, false), 8, 0, sysinfo.Memory{}, Topology{8, 1}, false},
+		{"lean, all fit in L3", fakeBackendInfo(mib, false), 8, 32 * mib, sysinfo.Memory{}, Topology{8, 1}, false},
+		{"lean, grouped to stay in L3", fakeBackendInfo(mib, false), 8, 4 * mib, sysinfo.Memory{}, Topology{4, 2}, false},
+		{"lean, rounded to divide threads", fakeBackendInfo(mib, false), 8, 3 * mib, sysinfo.Memory{}, Topology{2, 4}, false},
+		{"graph bigger than L3", fakeBackendInfo(64*mib, false), 8, 32 * mib, sysinfo.Memory{}, Topology{8, 1}, false},
+		{"memory bound", fakeBackendInfo(1<<30, false), 8, 0, sysinfo.Memory{Available: 4 << 30}, Topology{2, 4}, false},
+		{"memory for one", fakeBackendInfo(1<<30, false), 8, 0, sysinfo.Memory{Available: 1 << 30}, Topology{1, 8}, false},
+		{"no threads", fakeBackendInfo(mib, false), 0, 0, sysinfo.Memory{}, Topology{}, true},
+	} {
+		got, err := autoTopology(c.info, params, c.threads, c.l3, c.mem)
+		if (err != nil) != c.wantFail || got != c.want {
+			t.Errorf("%s: autoTopology = %v, %v; want %v", c.name, got, err, c.want)
+		}
+	}
+
+	failing := pkgsolver.BackendInfo{Memory: func(pkgsolver.Params, int) (uint64, error) {
+		return 0, errors.New("no such graph")
+	}}
+	if _, err := autoTopology(failing, params, 4, 0, sysinfo.Memory{}); err == nil {
+		t.Error("autoTopology: expected the backend's memory error")
+	}
+}
+
+func TestResolveTopology(t *testing.T) {
+	info := fakeBackendInfo(mib, false)
+	params := pkgsolver.Params{EdgeBits: 19, ProofSize: 42}
+	// An explicit layout ignores the machine
+	if got, err := resolveTopology("3x5", info, params, 8, 4*mib, sysinfo.Memory{}); err != nil || got != (Topology{3, 5}) {
+		t.Errorf("resolveTopology(3x5) = %v, %v", got, err)
+	}
+	if got, err := resolveTopology("auto", info, params, 8, 4*mib, sysinfo.Memory{}); err != nil || got != (Topology{4, 2}) {
+		t.Errorf("resolveTopology(auto) = %v, %v", got, err)
+	}
+	if _, err := resolveTopology("lots", info, params, 8, 0, sysinfo.Memory{}); err == nil {
+		t.Error("resolveTopology(lots): expected error")
+	}
+}
+
+func TestResizeTopology(t *testing.T) {
+	for _, c := range []struct {
+		from          Topology
+		n             int
+		multiThreaded bool
+		want          Topology
+	}{
+		{Topology{4, 2}, 8, false, Topology{4, 2}},
+		{Topology{4, 2}, 12, false, Topology{6, 2}},
+		{Topology{4, 2}, 5, false, Topology{2, 2}},
+		{Topology{4, 2}, 1, false, Topology{1, 1}}, // narrower than a solver
+		{Topology{2, 8}, 4, true, Topology{1, 4}},
+		{Topology{8, 1}, 3, false, Topology{3, 1}},
+		{Topology{1, 16}, 4, true, Topology{1, 4}},
+		{Topology{1, 16}, 32, true, Topology{1, 32}},
+		{Topology{2, 8}, 24, true, Topology{3, 8}}, // a multi-threaded layout split by hand
+	} {
+		if got := resizeTopology(c.from, c.n, c.multiThreaded); got != c.want {
+			t.Errorf("resizeTopology(%v, %d, %v) = %v, want %v", c.from, c.n, c.multiThreaded, got, c.want)
+		}
+	}
+}
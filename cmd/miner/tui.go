@@ -0,0 +1,582 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nitrogen/go-miner/pkg/events"
+	pkgsolver "github.com/nitrogen/go-miner/pkg/solver"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/term"
+)
+
+const (
+	// dashRefresh is how often the dashboard redraws
+	dashRefresh = time.Second
+	// dashShares and dashLogLines are how many share events and log lines
+	// the dashboard keeps
+	dashShares   = 100
+	dashLogLines = 500
+	// dashSamples is how many seconds of graph rates the sparkline keeps
+	dashSamples = 240
+)
+
+// ANSI escapes the dashboard draws with
+const (
+	ansiAltScreen  = "\x1b[?1049h\x1b[?25l" // and hide the cursor
+	ansiMainScreen = "\x1b[?25h\x1b[?1049l"
+	ansiHome       = "\x1b[H"
+	ansiClearLine  = "\x1b[K"
+	ansiClearBelow = "\x1b[J"
+	ansiBold       = "\x1b[1m"
+	ansiRed        = "\x1b[31m"
+	ansiGreen      = "\x1b[32m"
+	ansiYellow     = "\x1b[33m"
+	ansiReset      = "\x1b[0m"
+)
+
+// logPane keeps the log lines the dashboard shows in place of stderr. Once
+// detached, lines go to stderr again.
+type logPane struct {
+	mu       sync.Mutex
+	lines    []logLine
+	detached bool
+}
+
+type logLine struct {
+	level zapcore.Level
+	text  string
+}
+
+func (p *logPane) add(level zapcore.Level, text string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.detached {
+		os.Stderr.WriteString(text)
+		return
+	}
+	// Stack traces and the like show as their first line
+	text, _, _ = strings.Cut(strings.TrimRight(text, "\n"), "\n")
+	p.lines = append(p.lines, logLine{level, text})
+	if len(p.lines) > dashLogLines {
+		p.lines = p.lines[len(p.lines)-dashLogLines:]
+	}
+}
+
+// detach sends further lines to stderr, after those kept if replay is set
+func (p *logPane) detach(replay bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.detached = true
+	if replay {
+		for _, l := range p.lines {
+			fmt.Fprintln(os.Stderr, l.text)
+		}
+	}
+	p.lines = nil
+}
+
+// last returns up to n of the latest lines at level lowest or above
+func (p *logPane) last(n int, lowest zapcore.Level) []logLine {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var out []logLine
+	for i := len(p.lines) - 1; i >= 0 && len(out) < n; i-- {
+		if p.lines[i].level >= lowest {
+			out = append(out, p.lines[i])
+		}
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// paneCore is a zap core that writes console-encoded entries to a logPane
+type paneCore struct {
+	zapcore.LevelEnabler
+	enc  zapcore.Encoder
+	pane *logPane
+}
+
+// newPaneLogger returns a logger that writes to a new logPane at level,
+// which the dashboard lowers to debug on demand
+func newPaneLogger(level zap.AtomicLevel) (*zap.Logger, *logPane) {
+	cfg := zap.NewDevelopmentEncoderConfig()
+	cfg.EncodeTime = zapcore.TimeEncoderOfLayout("15:04:05")
+	cfg.CallerKey = ""
+	cfg.StacktraceKey = ""
+	cfg.ConsoleSeparator = "  " // tabs would throw off line widths
+	pane := &logPane{}
+	return zap.New(&paneCore{level, zapcore.NewConsoleEncoder(cfg), pane}), pane
+}
+
+func (c *paneCore) With(fields []zapcore.Field) zapcore.Core {
+	enc := c.enc.Clone()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	return &paneCore{c.LevelEnabler, enc, c.pane}
+}
+
+func (c *paneCore) Check(e zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(e.Level) {
+		return ce.AddCore(e, c)
+	}
+	return ce
+}
+
+func (c *paneCore) Write(e zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(e, fields)
+	if err != nil {
+		return err
+	}
+	c.pane.add(e.Level, buf.String())
+	buf.Free()
+	return nil
+}
+
+func (c *paneCore) Sync() error { return nil }
+
+// dashboard is the full-screen terminal view of the miner. It takes over
+// the terminal in raw mode: keys act at once, and the miner's log shows in
+// a pane instead of scrolling past.
+type dashboard struct {
+	m     *Miner
+	pane  *logPane
+	quit  func()
+	fd    int
+	state *term.State
+	// level is the logger's; verbose lowers it to debug, and turning
+	// verbose off or stopping restores baseLevel
+	level     zap.AtomicLevel
+	baseLevel zapcore.Level
+
+	mu         sync.Mutex
+	stopped    bool
+	verbose    bool
+	note       string // result of the last key, shown in the footer
+	shares     []events.Event
+	rates      []float64 // graphs per second, one per dashRefresh
+	lastGraphs uint64
+	lastSample time.Time
+
+	redraw chan struct{}
+	done   chan struct{}
+}
+
+// startDashboard draws m on the terminal until stop. quit is called for q
+// or Ctrl-C, which raw mode keeps from raising SIGINT. level is the level
+// of the logger writing to pane.
+func startDashboard(m *Miner, pane *logPane, level zap.AtomicLevel, quit func()) (*dashboard, error) {
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, fmt.Errorf("dashboard: %w", err)
+	}
+	d := &dashboard{
+		m:          m,
+		pane:       pane,
+		quit:       quit,
+		fd:         fd,
+		state:      state,
+		level:      level,
+		baseLevel:  level.Level(),
+		lastGraphs: m.stats.Graphs.Total(),
+		lastSample: time.Now(),
+		redraw:     make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+	m.events.Subscribe("dashboard", events.SinkFunc(d.handleEvent),
+		events.ShareAccepted, events.ShareRejected, events.Disconnected, events.Reconnected, events.PoolSwitched)
+	os.Stdout.WriteString(ansiAltScreen)
+	go d.readKeys()
+	go d.run()
+	return d, nil
+}
+
+// stop gives the terminal back; further log lines go to stderr
+func (d *dashboard) stop() {
+	d.mu.Lock()
+	if d.stopped {
+		d.mu.Unlock()
+		return
+	}
+	d.stopped = true
+	d.setLevel(d.baseLevel)
+	d.mu.Unlock()
+	close(d.done)
+	os.Stdout.WriteString(ansiMainScreen)
+	term.Restore(d.fd, d.state)
+	d.pane.detach(false)
+}
+
+// setLevel sets the log level, for the solvers too
+func (d *dashboard) setLevel(level zapcore.Level) {
+	d.level.SetLevel(level)
+	pkgsolver.SyncLogLevel()
+}
+
+func (d *dashboard) handleEvent(e events.Event) error {
+	d.mu.Lock()
+	d.shares = append(d.shares, e)
+	if len(d.shares) > dashShares {
+		d.shares = d.shares[len(d.shares)-dashShares:]
+	}
+	d.mu.Unlock()
+	d.requestRedraw()
+	return nil
+}
+
+func (d *dashboard) requestRedraw() {
+	select {
+	case d.redraw <- struct{}{}:
+	default:
+	}
+}
+
+func (d *dashboard) run() {
+	ticker := time.NewTicker(dashRefresh)
+	defer ticker.Stop()
+	d.draw()
+	for {
+		select {
+		case now := <-ticker.C:
+			d.sample(now)
+		case <-d.redraw:
+		case <-d.done:
+			return
+		}
+		d.draw()
+	}
+}
+
+// sample records the graph rate since the previous sample
+func (d *dashboard) sample(now time.Time) {
+	graphs := d.m.stats.Graphs.Total()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if dt := now.Sub(d.lastSample).Seconds(); dt > 0 {
+		d.rates = append(d.rates, float64(graphs-d.lastGraphs)/dt)
+		if len(d.rates) > dashSamples {
+			d.rates = d.rates[len(d.rates)-dashSamples:]
+		}
+	}
+	d.lastGraphs, d.lastSample = graphs, now
+}
+
+// readKeys acts on keys until the dashboard stops
+func (d *dashboard) readKeys() {
+	buf := make([]byte, 16)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return
+		}
+		d.mu.Lock()
+		stopped := d.stopped
+		d.mu.Unlock()
+		if stopped {
+			return
+		}
+		// Escape sequences (arrows and the like) are ignored whole
+		if n > 1 && buf[0] == 0x1b {
+			continue
+		}
+		for _, key := range buf[:n] {
+			d.handleKey(key)
+		}
+	}
+}
+
+func (d *dashboard) handleKey(key byte) {
+	note := ""
+	switch key {
+	case 'p', 'P', ' ':
+		d.m.solversMu.Lock()
+		paused := d.m.holds[holdUser]
+		d.m.solversMu.Unlock()
+		if !paused {
+			d.m.Pause()
+			note = "paused"
+		} else {
+			d.m.Resume()
+			note = "resumed"
+		}
+	case '+', '=':
+		note = d.adjustThreads(1)
+	case '-', '_':
+		note = d.adjustThreads(-1)
+	case 'v', 'V':
+		d.mu.Lock()
+		d.verbose = !d.verbose
+		note = "log shows warnings only"
+		level := d.baseLevel
+		if d.verbose {
+			note = "log shows everything, debug included"
+			level = zapcore.DebugLevel
+		}
+		d.setLevel(level)
+		d.mu.Unlock()
+	case 'q', 'Q', 3: // Ctrl-C
+		note = "stopping..."
+		d.quit()
+	default:
+		return
+	}
+	d.mu.Lock()
+	d.note = note
+	d.mu.Unlock()
+	d.requestRedraw()
+}
+
+// adjustThreads changes the requested thread count by delta
+func (d *dashboard) adjustThreads(delta int) string {
+	d.m.solversMu.Lock()
+	n := d.m.threads + delta
+	d.m.solversMu.Unlock()
+	if err := d.m.SetThreads(n); err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("threads set to %d", n)
+}
+
+// draw renders the whole screen
+func (d *dashboard) draw() {
+	width, height, err := term.GetSize(d.fd)
+	if err != nil || width < 20 || height < 10 {
+		width, height = 80, 24
+	}
+	st := d.m.Status()
+	c := d.m.client.Load()
+
+	d.mu.Lock()
+	verbose, note := d.verbose, d.note
+	shares := append([]events.Event(nil), d.shares...)
+	rates := append([]float64(nil), d.rates...)
+	d.mu.Unlock()
+
+	var lines []string
+	add := func(style, s string) {
+		s = fit(s, width)
+		if style != "" {
+			s = style + s + ansiReset
+		}
+		lines = append(lines, s)
+	}
+	// addAfter adds s after a short styled prefix
+	addAfter := func(prefix, s string) {
+		lines = append(lines, prefix+fit(s, width-utf8.RuneCountInString(stripANSI(prefix))))
+	}
+
+	// Pool and job
+	conn := ansiRed + "disconnected" + ansiReset
+	if st.Connected {
+		conn = ansiGreen + "connected" + ansiReset +
+			" " + formatUptime(time.Since(c.ConnectedSince()))
+	}
+	add(ansiBold, fmt.Sprintf("go-miner  up %s", formatUptime(time.Duration(st.Uptime*float64(time.Second)))))
+	addAfter(conn, fmt.Sprintf("  pool %s  user %s  reconnects %d", st.Pool, st.User, c.Reconnects()))
+	job := st.Job
+	if job == "" {
+		job = "-"
+	}
+	add("", fmt.Sprintf("job %s  difficulty %g", job, st.Difficulty))
+
+	// Mining state
+	state := ansiGreen + "mining" + ansiReset
+	if len(st.PausedBy) > 0 {
+		state = ansiYellow + "paused by " + strings.Join(st.PausedBy, ", ") + ansiReset
+	}
+	d.m.solversMu.Lock()
+	asked := d.m.threads
+	d.m.solversMu.Unlock()
+	threads := fmt.Sprintf("threads %d", st.Threads)
+	if asked != st.Threads {
+		threads += fmt.Sprintf(" of %d", asked)
+	}
+	for _, reason := range sortedKeys(st.ThreadCaps) {
+		threads += fmt.Sprintf(", %s cap %d", reason, st.ThreadCaps[reason])
+	}
+	extra := fmt.Sprintf("  %s  %s %s", threads, st.Backend, st.Topology)
+	if st.CPUTemp != nil {
+		extra += fmt.Sprintf("  %.0f°C", *st.CPUTemp)
+	}
+	addAfter(state, extra)
+
+	// Rates and shares
+	rejectRate := 0.0
+	if n := st.Shares.Accepted + st.Shares.Rejected; n > 0 {
+		rejectRate = 100 * float64(st.Shares.Rejected) / float64(n)
+	}
+	add("", fmt.Sprintf("graphs/s 1m/5m/15m %s  total %d  cycles %d  shares %d accepted, %d rejected (%.1f%%)",
+		formatRates(d.m.stats.Graphs.Rates(), 1), st.Graphs, st.Solutions,
+		st.Shares.Accepted, st.Shares.Rejected, rejectRate))
+	peak := 0.0
+	for _, r := range rates {
+		peak = max(peak, r)
+	}
+	spark := sparkline(rates, width-24)
+	add("", fmt.Sprintf("%s  peak %.1f/s", spark, peak))
+	lines = append(lines, "")
+
+	// Workers
+	add(ansiBold, fmt.Sprintf("%-8s %12s %14s  %s", "WORKER", "GRAPHS/S 1m", "GRAPHS", "PLACEMENT"))
+	footer := 2
+	room := height - len(lines) - footer - 4 // two headings and two gaps
+	shown := min(len(st.Workers), max(1, room/3))
+	for _, w := range st.Workers[:shown] {
+		add("", fmt.Sprintf("%-8d %12.1f %14d  %s", w.ID, w.GraphsPerSec, w.Graphs, w.Placement))
+	}
+	if shown < len(st.Workers) {
+		add("", fmt.Sprintf("... %d more", len(st.Workers)-shown))
+	}
+	lines = append(lines, "")
+
+	// Share and connection events, then the log, in what room is left
+	room = height - len(lines) - footer - 3
+	shareRows := max(1, room/2)
+	add(ansiBold, "SHARES")
+	if len(shares) > shareRows {
+		shares = shares[len(shares)-shareRows:]
+	}
+	for _, e := range shares {
+		text, style := describeEvent(e)
+		add(style, e.Time.Format("15:04:05")+" "+text)
+	}
+	for i := len(shares); i < shareRows; i++ {
+		lines = append(lines, "")
+	}
+	lines = append(lines, "")
+	minLevel, title := zapcore.WarnLevel, "LOG (warnings; v for all)"
+	if verbose {
+		minLevel, title = zapcore.DebugLevel, "LOG (all; v for warnings)"
+	}
+	add(ansiBold, title)
+	logRows := max(0, height-len(lines)-footer)
+	for _, l := range d.pane.last(logRows, minLevel) {
+		style := ""
+		if l.level >= zapcore.ErrorLevel {
+			style = ansiRed
+		} else if l.level == zapcore.WarnLevel {
+			style = ansiYellow
+		}
+		add(style, l.text)
+	}
+	for len(lines) < height-footer {
+		lines = append(lines, "")
+	}
+
+	lines = append(lines, "")
+	keys := "p pause/resume  +/- threads  v verbose  q quit"
+	if note != "" {
+		keys += "  | " + note
+	}
+	add(ansiBold, keys)
+
+	var b strings.Builder
+	b.WriteString(ansiHome)
+	for i, l := range lines[:min(len(lines), height)] {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(l)
+		b.WriteString(ansiClearLine)
+	}
+	b.WriteString(ansiClearBelow)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.stopped {
+		os.Stdout.WriteString(b.String())
+	}
+}
+
+// describeEvent is one line of the share log and its style
+func describeEvent(e events.Event) (string, string) {
+	switch e.Type {
+	case events.ShareAccepted:
+		return fmt.Sprintf("accepted  job %s  diff %g  %.0fms", e.JobID, e.Difficulty, e.LatencyMs), ansiGreen
+	case events.ShareRejected:
+		return fmt.Sprintf("rejected  job %s  diff %g  %s", e.JobID, e.Difficulty, e.Reason), ansiRed
+	case events.Disconnected:
+		return fmt.Sprintf("disconnected from %s: %s", e.Pool, e.Reason), ansiYellow
+	case events.PoolSwitched:
+		return fmt.Sprintf("switched pool from %s to %s", e.From, e.Pool), ""
+	default:
+		return fmt.Sprintf("%s %s", e.Type, e.Pool), ""
+	}
+}
+
+// sparkBars are the sparkline's levels, lowest first
+var sparkBars = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline draws the last width values scaled to the largest of them
+func sparkline(values []float64, width int) string {
+	if width < 1 {
+		return ""
+	}
+	if len(values) > width {
+		values = values[len(values)-width:]
+	}
+	peak := 0.0
+	for _, v := range values {
+		peak = max(peak, v)
+	}
+	var b strings.Builder
+	for i := len(values); i < width; i++ {
+		b.WriteByte(' ')
+	}
+	for _, v := range values {
+		if peak == 0 || v <= 0 {
+			b.WriteByte(' ')
+			continue
+		}
+		b.WriteRune(sparkBars[min(len(sparkBars)-1, int(v/peak*float64(len(sparkBars))))])
+	}
+	return b.String()
+}
+
+// fit cuts s to width runes
+func fit(s string, width int) string {
+	if width < 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	return string([]rune(s)[:width])
+}
+
+// stripANSI removes escape sequences, to measure what shows
+func stripANSI(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == 0x1b {
+			for i < len(s) && s[i] != 'm' {
+				i++
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// formatUptime shows d as e.g. 3d4h, 2h05m or 4m07s
+func formatUptime(d time.Duration) string {
+	d = d.Round(time.Second)
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd%dh", d/(24*time.Hour), d%(24*time.Hour)/time.Hour)
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh%02dm", d/time.Hour, d%time.Hour/time.Minute)
+	default:
+		return fmt.Sprintf("%dm%02ds", d/time.Minute, d%time.Minute/time.Second)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	pkgsolver "github.com/nitrogen/go-miner/pkg/solver"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestVerboseLogsDebug(t *testing.T) {
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	logger, pane := newPaneLogger(level)
+	d := &dashboard{pane: pane, level: level, baseLevel: level.Level()}
+
+	logger.Debug("before")
+	d.handleKey('v')
+	logger.Debug("verbose")
+	d.handleKey('v')
+	logger.Debug("after")
+	logger.Info("info")
+
+	var got []string
+	for _, l := range pane.last(10, zapcore.DebugLevel) {
+		got = append(got, l.text)
+	}
+	if len(got) != 2 || !strings.Contains(got[0], "verbose") || !strings.Contains(got[1], "info") {
+		t.Errorf("log lines = %q, want the debug line logged while verbose and the info line", got)
+	}
+	if level.Level() != zapcore.InfoLevel {
+		t.Errorf("level = %v after verbose off, want info", level.Level())
+	}
+}
+
+func TestVerboseLogsSolverDebug(t *testing.T) {
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	logger, pane := newPaneLogger(level)
+	pkgsolver.SetLogger(logger.Named("solver"))
+	defer pkgsolver.SetLogger(nil)
+	d := &dashboard{pane: pane, level: level, baseLevel: level.Level()}
+
+	s, err := pkgsolver.NewSolver(pkgsolver.Options{Params: pkgsolver.Params{EdgeBits: 19, ProofSize: 42}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.SetHeader(make([]byte, 80))
+	solverLines := func() int {
+		t.Helper()
+		if _, err := s.Solve(0, 1); err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for _, l := range pane.last(1000, zapcore.DebugLevel) {
+			if l.level == zapcore.DebugLevel && strings.Contains(l.text, "solver") {
+				n++
+			}
+		}
+		return n
+	}
+
+	if n := solverLines(); n != 0 {
+		t.Fatalf("%d solver debug lines before verbose", n)
+	}
+	d.handleKey('v')
+	if n := solverLines(); n == 0 {
+		t.Error("no solver debug lines once verbose")
+	}
+}
@@ -2,11 +2,16 @@ module github.com/nitrogen/go-miner
 
 go 1.22
 
-require go.uber.org/zap v1.27.0
+require (
+	go.etcd.io/bbolt v1.3.11
+	go.uber.org/zap v1.27.0
+	golang.org/x/term v0.27.0
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.2-0.20180830191138-d8f796af33cc // indirect
 	github.com/pmezard/go-difflib v1.0.1-0.20181226105442-5d4384ee4fb2 // indirect
 	github.com/stretchr/testify v1.8.4 // indirect
 	go.uber.org/multierr v1.10.0 // indirect
+	golang.org/x/sys v0.28.0 // indirect
 )
@@ -0,0 +1,13 @@
+// Package affinity pins the calling OS thread to a set of CPUs and a NUMA
+// node. Threads the pinned thread creates afterwards, such as the C solver's
+// pthreads, inherit both, so pinning the goroutine that drives a solver
+// places the whole solver.
+package affinity
+
+import "errors"
+
+// ErrUnsupported is returned where the OS offers no thread affinity
+var ErrUnsupported = errors.New("affinity: not supported on this platform")
+
+// maxCPUs bounds the CPU ids a mask can hold
+const maxCPUs = 1024
@@ -0,0 +1,47 @@
+package affinity
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// mpolPreferred is MPOL_PREFERRED from <linux/mempolicy.h>: allocate on the
+// given node, falling back to others when it is full
+const mpolPreferred = 1
+
+// Pin restricts the calling OS thread to cpus and, if node is not negative,
+// asks the kernel to allocate its memory on node. The caller must hold
+// runtime.LockOSThread for as long as the pinning should apply.
+func Pin(cpus []int, node int) error {
+	if len(cpus) == 0 {
+		return fmt.Errorf("affinity: empty cpu set")
+	}
+	var mask [maxCPUs / 64]uint64
+	for _, cpu := range cpus {
+		if cpu < 0 || cpu >= maxCPUs {
+			return fmt.Errorf("affinity: cpu %d out of range", cpu)
+		}
+		mask[cpu/64] |= 1 << (cpu % 64)
+	}
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, 0,
+		unsafe.Sizeof(mask), uintptr(unsafe.Pointer(&mask[0])))
+	if errno != 0 {
+		return fmt.Errorf("affinity: sched_setaffinity: %w", errno)
+	}
+
+	if node < 0 {
+		return nil
+	}
+	if node >= maxCPUs {
+		return fmt.Errorf("affinity: node %d out of range", node)
+	}
+	var nodes [maxCPUs / 64]uint64
+	nodes[node/64] |= 1 << (node % 64)
+	_, _, errno = syscall.RawSyscall(syscall.SYS_SET_MEMPOLICY, mpolPreferred,
+		uintptr(unsafe.Pointer(&nodes[0])), uintptr(len(nodes)*64))
+	if errno != 0 {
+		return fmt.Errorf("affinity: set_mempolicy: %w", errno)
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package affinity
+
+// Pin is not implemented outside Linux
+func Pin(cpus []int, node int) error {
+	return ErrUnsupported
+}
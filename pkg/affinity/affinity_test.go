@@ -0,0 +1,23 @@
+//go:build linux
+
+package affinity
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestPin(t *testing.T) {
+	// Never unlocked: the thread exits with the test goroutine, pinning and all
+	runtime.LockOSThread()
+
+	if err := Pin(nil, -1); err == nil {
+		t.Error("expected error for empty cpu set")
+	}
+	if err := Pin([]int{maxCPUs}, -1); err == nil {
+		t.Error("expected error for out of range cpu")
+	}
+	if err := Pin([]int{0}, 0); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,197 @@
+// Package events carries the miner's share, job and connection events to
+// subscribers such as log files, webhooks and hook commands.
+package events
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Type names an event
+type Type string
+
+const (
+	JobReceived       Type = "job"
+	ShareAccepted     Type = "share_accepted"
+	ShareRejected     Type = "share_rejected"
+	DifficultyChanged Type = "difficulty"
+	Disconnected      Type = "disconnected"
+	Reconnected       Type = "reconnected"
+	PoolSwitched      Type = "pool_switched"
+)
+
+// Types lists every event type
+var Types = []Type{JobReceived, ShareAccepted, ShareRejected, DifficultyChanged, Disconnected, Reconnected, PoolSwitched}
+
+// ParseTypes parses a comma-separated list of event types; empty means all
+func ParseTypes(s string) ([]Type, error) {
+	var types []Type
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		known := false
+		for _, t := range Types {
+			known = known || Type(f) == t
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown event type %q", f)
+		}
+		types = append(types, Type(f))
+	}
+	return types, nil
+}
+
+// Event is something that happened to the miner. Fields that don't apply
+// to its type are left empty.
+type Event struct {
+	Time       time.Time `json:"time"`
+	Type       Type      `json:"type"`
+	Pool       string    `json:"pool,omitempty"`
+	JobID      string    `json:"job_id,omitempty"`
+	Difficulty float64   `json:"difficulty,omitempty"`
+	// LatencyMs is how long the pool took to answer a share
+	LatencyMs float64 `json:"latency_ms,omitempty"`
+	// Reason is why a share was rejected or the connection was lost
+	Reason string `json:"reason,omitempty"`
+	// From is the previous pool of a PoolSwitched
+	From string `json:"from,omitempty"`
+}
+
+// Sink receives events. A Bus calls Handle from one goroutine per sink,
+// and Close once no more events will come.
+type Sink interface {
+	Handle(Event) error
+	Close() error
+}
+
+// SinkFunc adapts a function to a Sink with nothing to close
+type SinkFunc func(Event) error
+
+func (f SinkFunc) Handle(e Event) error { return f(e) }
+func (f SinkFunc) Close() error         { return nil }
+
+// queueSize is how many events a sink may fall behind before they are
+// dropped
+const queueSize = 256
+
+// Bus fans events out to sinks. Each sink has its own queue and goroutine,
+// so a slow one neither blocks publishers nor delays the others.
+type Bus struct {
+	logger *zap.Logger
+
+	mu      sync.RWMutex
+	subs    []*subscription
+	closed  bool
+	dropped atomic.Uint64
+}
+
+type subscription struct {
+	name  string
+	sink  Sink
+	types map[Type]bool // nil for all
+	queue chan Event
+	done  chan struct{}
+}
+
+// NewBus returns a bus without sinks; sink errors go to logger
+func NewBus(logger *zap.Logger) *Bus {
+	return &Bus{logger: logger}
+}
+
+// Subscribe sends the events of the given types, or all with none, to
+// sink. name identifies it in logs.
+func (b *Bus) Subscribe(name string, sink Sink, types ...Type) {
+	s := &subscription{
+		name:  name,
+		sink:  sink,
+		queue: make(chan Event, queueSize),
+		done:  make(chan struct{}),
+	}
+	if len(types) > 0 {
+		s.types = make(map[Type]bool)
+		for _, t := range types {
+			s.types[t] = true
+		}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		sink.Close()
+		return
+	}
+	b.subs = append(b.subs, s)
+	go s.run(b.logger)
+}
+
+// Publish queues e for the sinks that want it, stamping the time if unset.
+// It never blocks: a sink whose queue is full misses the event.
+func (b *Bus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return
+	}
+	for _, s := range b.subs {
+		if s.types != nil && !s.types[e.Type] {
+			continue
+		}
+		select {
+		case s.queue <- e:
+		default:
+			b.dropped.Add(1)
+		}
+	}
+}
+
+// Dropped returns how many events sinks have missed for full queues
+func (b *Bus) Dropped() uint64 {
+	return b.dropped.Load()
+}
+
+// Close stops accepting events and waits up to timeout for the sinks to
+// handle those queued, reporting whether they all did
+func (b *Bus) Close(timeout time.Duration) bool {
+	b.mu.Lock()
+	subs := b.subs
+	if !b.closed {
+		b.closed = true
+		for _, s := range subs {
+			close(s.queue)
+		}
+	}
+	b.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for _, s := range subs {
+		select {
+		case <-s.done:
+		case <-timer.C:
+			return false
+		}
+	}
+	return true
+}
+
+func (s *subscription) run(logger *zap.Logger) {
+	defer close(s.done)
+	for e := range s.queue {
+		if err := s.sink.Handle(e); err != nil {
+			logger.Warn("Event sink failed",
+				zap.String("sink", s.name), zap.String("event", string(e.Type)), zap.Error(err))
+		}
+	}
+	if err := s.sink.Close(); err != nil {
+		logger.Warn("Failed to close event sink", zap.String("sink", s.name), zap.Error(err))
+	}
+}
@@ -0,0 +1,186 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// recorder is a sink that keeps what it handles
+type recorder struct {
+	mu     sync.Mutex
+	events []Event
+	closed bool
+}
+
+func (r *recorder) Handle(e Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+	return nil
+}
+
+func (r *recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	return nil
+}
+
+func TestParseTypes(t *testing.T) {
+	types, err := ParseTypes("share_rejected, disconnected")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(types) != 2 || types[0] != ShareRejected || types[1] != Disconnected {
+		t.Fatalf("ParseTypes = %v", types)
+	}
+	if types, err := ParseTypes(""); err != nil || types != nil {
+		t.Fatalf("ParseTypes(\"\") = %v, %v", types, err)
+	}
+	if _, err := ParseTypes("share"); err == nil {
+		t.Fatal("ParseTypes(\"share\"): expected error")
+	}
+}
+
+func TestBusFiltersAndDrains(t *testing.T) {
+	b := NewBus(zap.NewNop())
+	all, rejects := &recorder{}, &recorder{}
+	b.Subscribe("all", all)
+	b.Subscribe("rejects", rejects, ShareRejected)
+
+	b.Publish(Event{Type: JobReceived, JobID: "1"})
+	b.Publish(Event{Type: ShareRejected, JobID: "1", Reason: "stale"})
+	b.Publish(Event{Type: ShareAccepted, JobID: "1"})
+	if !b.Close(time.Second) {
+		t.Fatal("Close timed out")
+	}
+	b.Publish(Event{Type: ShareRejected}) // after Close: dropped silently
+
+	if len(all.events) != 3 || !all.closed {
+		t.Fatalf("all: %d events, closed %v", len(all.events), all.closed)
+	}
+	if len(rejects.events) != 1 || rejects.events[0].Reason != "stale" {
+		t.Fatalf("rejects: %+v", rejects.events)
+	}
+	if all.events[0].Time.IsZero() {
+		t.Error("Publish did not stamp the time")
+	}
+}
+
+func TestBusDropsForSlowSink(t *testing.T) {
+	b := NewBus(zap.NewNop())
+	release := make(chan struct{})
+	b.Subscribe("slow", SinkFunc(func(Event) error {
+		<-release
+		return nil
+	}))
+	// One event is taken off the queue and blocks; the queue holds the rest
+	for i := 0; i < queueSize+10; i++ {
+		b.Publish(Event{Type: JobReceived})
+	}
+	if d := b.Dropped(); d < 9 || d > 10 {
+		t.Errorf("Dropped = %d, want 9 or 10", d)
+	}
+	if b.Close(10 * time.Millisecond) {
+		t.Error("Close reported a blocked sink as drained")
+	}
+	close(release)
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	f, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Handle(Event{Type: ShareAccepted, JobID: "7", LatencyMs: 12.5})
+	f.Handle(Event{Type: Disconnected, Reason: "EOF"})
+	f.Close()
+
+	data, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer data.Close()
+	var got []Event
+	for sc := bufio.NewScanner(data); sc.Scan(); {
+		var e Event
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("line %q: %v", sc.Text(), err)
+		}
+		got = append(got, e)
+	}
+	if len(got) != 2 || got[0].JobID != "7" || got[0].LatencyMs != 12.5 || got[1].Reason != "EOF" {
+		t.Fatalf("read back %+v", got)
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e Event
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil || e.Type != ShareRejected {
+			t.Errorf("body: %+v, %v", e, err)
+		}
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	w := NewWebhook(srv.URL)
+	w.Backoff = time.Millisecond
+	if err := w.Handle(Event{Type: ShareRejected}); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("%d attempts, want 3", calls.Load())
+	}
+
+	// Client errors are not retried
+	calls.Store(0)
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	})
+	if err := w.Handle(Event{Type: ShareRejected}); err == nil {
+		t.Fatal("expected error for 404")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("%d attempts for 404, want 1", calls.Load())
+	}
+}
+
+func TestExec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	out := filepath.Join(t.TempDir(), "out")
+	s := &Exec{Command: `printf '%s ' "$MINER_EVENT" "$MINER_REASON" > ` + out + ` && cat >> ` + out}
+	if err := s.Handle(Event{Type: Disconnected, Reason: "EOF"}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); !strings.HasPrefix(got, `disconnected EOF {"time"`) {
+		t.Errorf("output %q", got)
+	}
+
+	if err := (&Exec{Command: "echo oops; exit 3"}).Handle(Event{}); err == nil || !strings.Contains(err.Error(), "oops") {
+		t.Errorf("failing command: %v", err)
+	}
+}
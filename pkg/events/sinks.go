@@ -0,0 +1,139 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// File appends events to a file as JSON lines
+type File struct {
+	f *os.File
+}
+
+// OpenFile opens path for appending, creating it if needed
+func OpenFile(path string) (*File, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &File{f: f}, nil
+}
+
+// Handle writes e as one line
+func (s *File) Handle(e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = s.f.Write(append(data, '\n'))
+	return err
+}
+
+// Close closes the file
+func (s *File) Close() error {
+	return s.f.Close()
+}
+
+// Webhook POSTs each event as JSON to a URL. Failed posts (network errors,
+// 5xx and 429 answers) are retried Retries times, waiting Backoff and then
+// twice as long before each further attempt.
+type Webhook struct {
+	URL     string
+	Retries int
+	Backoff time.Duration
+	Client  *http.Client
+}
+
+// NewWebhook returns a webhook to url that retries 3 times from 1s apart
+func NewWebhook(url string) *Webhook {
+	return &Webhook{
+		URL:     url,
+		Retries: 3,
+		Backoff: time.Second,
+		Client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Handle posts e, retrying as configured
+func (s *Webhook) Handle(e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	wait := s.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := s.post(body)
+		if err == nil || !retry || attempt == s.Retries {
+			return err
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+// post sends body once, reporting whether a failure is worth retrying
+func (s *Webhook) post(body []byte) (retry bool, err error) {
+	resp, err := s.Client.Post(s.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("webhook: %s", resp.Status)
+	}
+	return false, nil
+}
+
+// Close releases idle connections
+func (s *Webhook) Close() error {
+	s.Client.CloseIdleConnections()
+	return nil
+}
+
+// execTimeout bounds each run of an Exec command
+const execTimeout = 30 * time.Second
+
+// Exec runs a shell command for each event, with the event as JSON on its
+// standard input and its main fields in MINER_EVENT, MINER_POOL, MINER_JOB
+// and MINER_REASON
+type Exec struct {
+	Command string
+}
+
+// Handle runs the command for e and waits for it to exit
+func (s *Exec) Handle(e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), execTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", s.Command)
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", s.Command)
+	}
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Env = append(os.Environ(),
+		"MINER_EVENT="+string(e.Type),
+		"MINER_POOL="+e.Pool,
+		"MINER_JOB="+e.JobID,
+		"MINER_REASON="+e.Reason,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// Close does nothing; each command has exited by the time Handle returns
+func (s *Exec) Close() error {
+	return nil
+}
@@ -0,0 +1,245 @@
+// Package history keeps share submissions and mining sessions in a local
+// bbolt file, so totals survive restarts and can be reported per day.
+package history
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	sharesBucket   = []byte("shares")
+	sessionsBucket = []byte("sessions")
+)
+
+// lockTimeout is how long to wait for another process holding the file
+const lockTimeout = 5 * time.Second
+
+// Share is one submission and the pool's answer
+type Share struct {
+	Time       time.Time `json:"time"`
+	Pool       string    `json:"pool"`
+	JobID      string    `json:"job_id"`
+	Difficulty float64   `json:"difficulty"`
+	Accepted   bool      `json:"accepted"`
+	Reason     string    `json:"reason,omitempty"` // why it was rejected
+	LatencyMs  float64   `json:"latency_ms"`
+}
+
+// Session is a stretch of mining on one pool: a run of the miner, or the
+// part of one between pool switches. End is moved forward while it lasts.
+type Session struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Pool  string    `json:"pool"`
+}
+
+// Store is an open history file. bbolt locks the file while it is open, so
+// a writer should open it only to write: the miner does once a minute, and
+// `miner history` reads it in between.
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens the history file for recording, creating it and its directory
+// if needed
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	db, err := open(path, false)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{sharesBucket, sessionsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// OpenReadOnly opens an existing history file for queries. It creates
+// nothing: a missing file is an error.
+func OpenReadOnly(path string) (*Store, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	db, err := open(path, true)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+func open(path string, readOnly bool) (*bolt.DB, error) {
+	db, err := bolt.Open(path, 0o644, &bolt.Options{Timeout: lockTimeout, ReadOnly: readOnly})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("history: %s is in use, likely by a running miner", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	return db, nil
+}
+
+// Close closes the file
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// AddShare records sh
+func (s *Store) AddShare(sh Share) error {
+	return s.db.Update(func(tx *bolt.Tx) error { return putShare(tx, sh) })
+}
+
+// SaveSession records ss, replacing the session with the same Start
+func (s *Store) SaveSession(ss Session) error {
+	return s.db.Update(func(tx *bolt.Tx) error { return putSession(tx, ss) })
+}
+
+// Record adds shares and saves ss in one transaction
+func (s *Store) Record(shares []Share, ss Session) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, sh := range shares {
+			if err := putShare(tx, sh); err != nil {
+				return err
+			}
+		}
+		return putSession(tx, ss)
+	})
+}
+
+func putShare(tx *bolt.Tx, sh Share) error {
+	value, err := json.Marshal(sh)
+	if err != nil {
+		return err
+	}
+	b := tx.Bucket(sharesBucket)
+	// Shares may share a timestamp; the sequence keeps keys unique
+	seq, err := b.NextSequence()
+	if err != nil {
+		return err
+	}
+	return b.Put(binary.BigEndian.AppendUint64(timeKey(sh.Time), seq), value)
+}
+
+func putSession(tx *bolt.Tx, ss Session) error {
+	value, err := json.Marshal(ss)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(sessionsBucket).Put(timeKey(ss.Start), value)
+}
+
+// Day sums up one pool's mining on one day
+type Day struct {
+	Date     time.Time // midnight in the location asked for
+	Pool     string
+	Accepted uint64
+	Rejected uint64
+	Uptime   time.Duration
+}
+
+// RejectRate is the share of submissions rejected, 0 without any
+func (d Day) RejectRate() float64 {
+	if n := d.Accepted + d.Rejected; n > 0 {
+		return float64(d.Rejected) / float64(n)
+	}
+	return 0
+}
+
+// Daily sums shares and session time per day and pool from since on,
+// splitting days in loc. Days are in order, pools by name within a day.
+func (s *Store) Daily(since time.Time, loc *time.Location) ([]Day, error) {
+	type dayPool struct {
+		date time.Time
+		pool string
+	}
+	days := make(map[dayPool]*Day)
+	get := func(t time.Time, pool string) *Day {
+		k := dayPool{midnight(t, loc), pool}
+		d := days[k]
+		if d == nil {
+			d = &Day{Date: k.date, Pool: pool}
+			days[k] = d
+		}
+		return d
+	}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(sharesBucket).Cursor()
+		for k, v := c.Seek(timeKey(since)); k != nil; k, v = c.Next() {
+			var sh Share
+			if err := json.Unmarshal(v, &sh); err != nil {
+				return err
+			}
+			if d := get(sh.Time, sh.Pool); sh.Accepted {
+				d.Accepted++
+			} else {
+				d.Rejected++
+			}
+		}
+		// Sessions are few, and one begun before since may run past it
+		return tx.Bucket(sessionsBucket).ForEach(func(_, v []byte) error {
+			var ss Session
+			if err := json.Unmarshal(v, &ss); err != nil {
+				return err
+			}
+			start := ss.Start
+			if start.Before(since) {
+				start = since
+			}
+			for start.Before(ss.End) {
+				next := midnight(start, loc).AddDate(0, 0, 1)
+				end := ss.End
+				if next.Before(end) {
+					end = next
+				}
+				get(start, ss.Pool).Uptime += end.Sub(start)
+				start = next
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Day, 0, len(days))
+	for _, d := range days {
+		out = append(out, *d)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].Date.Equal(out[j].Date) {
+			return out[i].Date.Before(out[j].Date)
+		}
+		return out[i].Pool < out[j].Pool
+	})
+	return out, nil
+}
+
+// timeKey orders records by time
+func timeKey(t time.Time) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(t.UnixNano()))
+}
+
+func midnight(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
@@ -0,0 +1,97 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDaily(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "sub", "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	at := func(day, h int) time.Time { return time.Date(2024, 3, day, h, 0, 0, 0, time.UTC) }
+
+	for _, sh := range []Share{
+		{Time: at(1, 10), Pool: "a:1", Accepted: true},
+		{Time: at(1, 10), Pool: "a:1", Accepted: true}, // same instant
+		{Time: at(1, 11), Pool: "a:1", Reason: "stale"},
+		{Time: at(1, 12), Pool: "b:2", Accepted: true},
+		{Time: at(2, 9), Pool: "a:1", Accepted: true},
+		{Time: at(3, 0).Add(-time.Nanosecond), Pool: "a:1"},
+	} {
+		if err := s.AddShare(sh); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A session over midnight, saved twice as it goes on
+	ss := Session{Start: at(1, 20), End: at(1, 21), Pool: "a:1"}
+	s.SaveSession(ss)
+	ss.End = at(2, 2)
+	s.SaveSession(ss)
+	s.SaveSession(Session{Start: at(1, 11), End: at(1, 12), Pool: "b:2"})
+
+	days, err := s.Daily(at(1, 0), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Day{
+		{Date: at(1, 0), Pool: "a:1", Accepted: 2, Rejected: 1, Uptime: 4 * time.Hour},
+		{Date: at(1, 0), Pool: "b:2", Accepted: 1, Uptime: time.Hour},
+		{Date: at(2, 0), Pool: "a:1", Accepted: 1, Rejected: 1, Uptime: 2 * time.Hour},
+	}
+	if len(days) != len(want) {
+		t.Fatalf("Daily = %+v", days)
+	}
+	for i := range want {
+		if days[i] != want[i] {
+			t.Errorf("day %d = %+v, want %+v", i, days[i], want[i])
+		}
+	}
+	if r := days[0].RejectRate(); r != 1.0/3 {
+		t.Errorf("RejectRate = %v", r)
+	}
+
+	// Starting mid-session counts only what follows
+	days, err = s.Daily(at(2, 1), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 1 || days[0].Uptime != time.Hour || days[0].Accepted != 1 {
+		t.Errorf("Daily from day 2, 01:00 = %+v", days)
+	}
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	if _, err := OpenReadOnly(path); err == nil {
+		t.Fatal("OpenReadOnly of a missing file: expected error")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("OpenReadOnly created the file: %v", err)
+	}
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local)
+	s.Record([]Share{{Time: now, Pool: "a:1", Accepted: true}}, Session{Start: now.Add(-time.Minute), End: now, Pool: "a:1"})
+	s.Close()
+
+	s, err = OpenReadOnly(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	days, err := s.Daily(now.Add(-time.Hour), time.Local)
+	if err != nil || len(days) != 1 || days[0].Accepted != 1 || days[0].Uptime != time.Minute {
+		t.Errorf("Daily = %+v, %v", days, err)
+	}
+	if err := s.AddShare(Share{Time: now}); err == nil {
+		t.Error("AddShare on a read-only store: expected error")
+	}
+}
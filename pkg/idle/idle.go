@@ -0,0 +1,166 @@
+// Package idle decides when a miner sharing its host should back off: while
+// other processes keep the CPUs busy, or outside the hours it may mine.
+package idle
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// State is what the miner should do
+type State int
+
+const (
+	Run      State = iota // mine with all threads
+	Throttle              // mine with fewer threads
+	Pause                 // don't mine
+)
+
+func (s State) String() string {
+	switch s {
+	case Run:
+		return "run"
+	case Throttle:
+		return "throttle"
+	case Pause:
+		return "pause"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// Window is a daily span of local time, from Start up to End since
+// midnight. It wraps past midnight when End is before Start.
+type Window struct {
+	Start, End time.Duration
+}
+
+// Contains reports whether t's time of day is in w
+func (w Window) Contains(t time.Time) bool {
+	h, m, s := t.Clock()
+	d := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(s)*time.Second
+	if w.Start <= w.End {
+		return d >= w.Start && d < w.End
+	}
+	return d >= w.Start || d < w.End
+}
+
+// ParseWindows parses comma-separated spans such as "22:00-07:00,12:00-13:30"
+func ParseWindows(spec string) ([]Window, error) {
+	var ws []Window
+	for _, f := range strings.Split(spec, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		from, to, ok := strings.Cut(f, "-")
+		start, err1 := parseClock(from)
+		end, err2 := parseClock(to)
+		if !ok || err1 != nil || err2 != nil || start == end {
+			return nil, fmt.Errorf("idle: bad window %q: want HH:MM-HH:MM", f)
+		}
+		ws = append(ws, Window{Start: start, End: end})
+	}
+	return ws, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Config sets when the miner backs off. Load is the share of all CPUs
+// (0 to 1) that other processes use.
+type Config struct {
+	Busy     float64       // load at which mining backs off; 0 never does
+	Idle     float64       // load at which it returns, below Busy
+	Hold     time.Duration // how long load must stay past a threshold to switch
+	Throttle bool          // back off to Throttle rather than Pause
+	Windows  []Window      // when mining is allowed; none means always
+}
+
+// Scheduler turns load samples into a State. It starts in Run. It is not
+// safe for concurrent use.
+type Scheduler struct {
+	cfg      Config
+	state    State
+	offHours bool
+	pending  time.Time // when load first crossed the threshold out of state
+}
+
+// New returns a scheduler for cfg
+func New(cfg Config) *Scheduler {
+	return &Scheduler{cfg: cfg}
+}
+
+// Update takes the load at now and returns the state to be in and why
+func (s *Scheduler) Update(now time.Time, load float64) (State, string) {
+	if !s.inWindow(now) {
+		s.state, s.offHours, s.pending = Pause, true, time.Time{}
+		return Pause, "outside mining hours"
+	}
+	if s.offHours {
+		// Back in hours: start from what the load calls for right away
+		s.offHours = false
+		if s.busy(load) {
+			s.state = s.backoff()
+			return s.state, "host busy"
+		}
+		s.state = Run
+		return Run, "mining hours"
+	}
+
+	leave := s.busy(load)
+	if s.state != Run {
+		leave = load <= s.cfg.Idle
+	}
+	if !leave {
+		s.pending = time.Time{}
+		return s.state, s.reason()
+	}
+	if s.pending.IsZero() {
+		s.pending = now
+	}
+	if now.Sub(s.pending) < s.cfg.Hold {
+		return s.state, s.reason()
+	}
+	s.pending = time.Time{}
+	if s.state == Run {
+		s.state = s.backoff()
+	} else {
+		s.state = Run
+	}
+	return s.state, s.reason()
+}
+
+func (s *Scheduler) busy(load float64) bool {
+	return s.cfg.Busy > 0 && load >= s.cfg.Busy
+}
+
+func (s *Scheduler) backoff() State {
+	if s.cfg.Throttle {
+		return Throttle
+	}
+	return Pause
+}
+
+func (s *Scheduler) reason() string {
+	if s.state == Run {
+		return "host idle"
+	}
+	return "host busy"
+}
+
+func (s *Scheduler) inWindow(t time.Time) bool {
+	if len(s.cfg.Windows) == 0 {
+		return true
+	}
+	for _, w := range s.cfg.Windows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
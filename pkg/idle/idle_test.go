@@ -0,0 +1,75 @@
+package idle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseWindows(t *testing.T) {
+	ws, err := ParseWindows("22:00-07:00, 12:00-13:30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ws) != 2 || ws[0] != (Window{22 * time.Hour, 7 * time.Hour}) ||
+		ws[1] != (Window{12 * time.Hour, 13*time.Hour + 30*time.Minute}) {
+		t.Fatalf("ParseWindows = %v", ws)
+	}
+	for _, bad := range []string{"22:00", "25:00-07:00", "08:00-08:00", "8-9"} {
+		if _, err := ParseWindows(bad); err == nil {
+			t.Errorf("ParseWindows(%q): expected error", bad)
+		}
+	}
+}
+
+func TestWindowContains(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2024, 1, 1, h, m, 0, 0, time.Local) }
+	night := Window{22 * time.Hour, 7 * time.Hour}
+	for _, c := range []struct {
+		t    time.Time
+		want bool
+	}{
+		{at(23, 0), true}, {at(3, 0), true}, {at(7, 0), false}, {at(12, 0), false}, {at(22, 0), true},
+	} {
+		if got := night.Contains(c.t); got != c.want {
+			t.Errorf("night.Contains(%v) = %v", c.t.Format("15:04"), got)
+		}
+	}
+}
+
+func TestSchedulerHysteresis(t *testing.T) {
+	s := New(Config{Busy: 0.5, Idle: 0.2, Hold: 10 * time.Second})
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+	step := func(load float64, want State) {
+		t.Helper()
+		now = now.Add(5 * time.Second)
+		if got, _ := s.Update(now, load); got != want {
+			t.Fatalf("load %v at %v: state %v, want %v", load, now.Format("15:04:05"), got, want)
+		}
+	}
+
+	step(0.9, Run) // busy, but not for Hold yet
+	step(0.1, Run) // a blip resets the hold
+	step(0.9, Run)
+	step(0.9, Run)
+	step(0.9, Pause) // busy for 10s
+	step(0.3, Pause) // between the thresholds: stay
+	step(0.1, Pause)
+	step(0.1, Pause)
+	step(0.1, Run)
+}
+
+func TestSchedulerThrottleAndWindows(t *testing.T) {
+	night, _ := ParseWindows("22:00-07:00")
+	s := New(Config{Busy: 0.5, Idle: 0.2, Throttle: true, Windows: night})
+
+	if got, why := s.Update(time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local), 0); got != Pause || why != "outside mining hours" {
+		t.Fatalf("midday: %v (%s), want pause", got, why)
+	}
+	// Entering the window starts from the load right away
+	if got, _ := s.Update(time.Date(2024, 1, 1, 22, 0, 0, 0, time.Local), 0.9); got != Throttle {
+		t.Fatalf("busy at 22:00: %v, want throttle", got)
+	}
+	if got, _ := s.Update(time.Date(2024, 1, 1, 22, 0, 5, 0, time.Local), 0.1); got != Run {
+		t.Fatalf("idle at 22:00:05: %v, want run", got)
+	}
+}
@@ -0,0 +1,113 @@
+// Package metrics renders samples in the Prometheus text exposition format
+// and serves them over HTTP, without pulling in the Prometheus client.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/nitrogen/go-miner/pkg/stats"
+)
+
+// Metric types, as written on # TYPE lines
+const (
+	Counter   = "counter"
+	Gauge     = "gauge"
+	Histogram = "histogram"
+)
+
+// Writer writes one scrape. Declare each metric once with Describe, then
+// write its samples; labels are given as name, value pairs.
+type Writer struct {
+	w *bufio.Writer
+}
+
+// NewWriter returns a Writer on w; call Flush when done
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+// Flush writes any buffered output
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+// Describe writes the # HELP and # TYPE lines of a metric
+func (w *Writer) Describe(name, typ, help string) {
+	fmt.Fprintf(w.w, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, typ)
+}
+
+// Sample writes one value of a metric
+func (w *Writer) Sample(name string, v float64, labels ...string) {
+	w.w.WriteString(name)
+	writeLabels(w.w, labels, "", "")
+	w.w.WriteByte(' ')
+	w.w.WriteString(formatValue(v))
+	w.w.WriteByte('\n')
+}
+
+// Histogram writes the buckets, sum and count of a histogram metric
+func (w *Writer) Histogram(name string, s stats.HistogramSnapshot, labels ...string) {
+	for i, b := range s.Bounds {
+		w.w.WriteString(name + "_bucket")
+		writeLabels(w.w, labels, "le", formatValue(b))
+		fmt.Fprintf(w.w, " %d\n", s.Counts[i])
+	}
+	w.w.WriteString(name + "_bucket")
+	writeLabels(w.w, labels, "le", "+Inf")
+	fmt.Fprintf(w.w, " %d\n", s.Count)
+	w.Sample(name+"_sum", s.Sum, labels...)
+	w.Sample(name+"_count", float64(s.Count), labels...)
+}
+
+// Handler serves the samples collect writes on each request
+func Handler(collect func(*Writer)) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w := NewWriter(rw)
+		collect(w)
+		w.Flush()
+	})
+}
+
+// writeLabels writes {k="v",...}, with extraK="extraV" appended when set
+func writeLabels(w *bufio.Writer, labels []string, extraK, extraV string) {
+	if len(labels) < 2 && extraK == "" {
+		return
+	}
+	w.WriteByte('{')
+	sep := ""
+	for i := 0; i+1 < len(labels); i += 2 {
+		fmt.Fprintf(w, "%s%s=\"%s\"", sep, labels[i], escapeLabel(labels[i+1]))
+		sep = ","
+	}
+	if extraK != "" {
+		fmt.Fprintf(w, "%s%s=\"%s\"", sep, extraK, escapeLabel(extraV))
+	}
+	w.WriteByte('}')
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nitrogen/go-miner/pkg/stats"
+)
+
+func TestHandler(t *testing.T) {
+	h := stats.NewHistogram([]float64{0.1, 1})
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(2)
+
+	srv := Handler(func(w *Writer) {
+		w.Describe("miner_graphs_total", Counter, "Graphs searched")
+		w.Sample("miner_graphs_total", 42)
+		w.Describe("miner_shares_total", Counter, "Shares by outcome")
+		w.Sample("miner_shares_total", 3, "outcome", "rejected", "reason", `low "diff"`)
+		w.Describe("rpc_seconds", Histogram, "RPC latency")
+		w.Histogram("rpc_seconds", h.Snapshot(), "method", "mining.submit")
+	})
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	want := `# HELP miner_graphs_total Graphs searched
+# TYPE miner_graphs_total counter
+miner_graphs_total 42
+# HELP miner_shares_total Shares by outcome
+# TYPE miner_shares_total counter
+miner_shares_total{outcome="rejected",reason="low \"diff\""} 3
+# HELP rpc_seconds RPC latency
+# TYPE rpc_seconds histogram
+rpc_seconds_bucket{method="mining.submit",le="0.1"} 1
+rpc_seconds_bucket{method="mining.submit",le="1"} 2
+rpc_seconds_bucket{method="mining.submit",le="+Inf"} 3
+rpc_seconds_sum{method="mining.submit"} 2.55
+rpc_seconds_count{method="mining.submit"} 3
+`
+	if got := rec.Body.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+}
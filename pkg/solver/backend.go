@@ -0,0 +1,115 @@
+package solver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Backend is one solver instance working on one graph at a time. *Solver
+// and *GoSolver implement it.
+type Backend interface {
+	SetHeader(header []byte) error
+	Solve(baseNonce uint32, nonceRange uint32) ([]Solution, error)
+	SolveContext(ctx context.Context, baseNonce uint32, nonceRange uint32) ([]Solution, error)
+	Cancel()
+	Close()
+	Capabilities() Capabilities
+	// LastGraphs reports how many graphs the last Solve searched to the
+	// end; graphs cut short by Cancel are not counted. Graphs are searched
+	// in nonce order, so the first LastGraphs nonces from baseNonce are
+	// done even when the Solve was cancelled, and the rest are not.
+	LastGraphs() int
+}
+
+// Capabilities describes a backend instance
+type Capabilities struct {
+	Backend   string    // registry name
+	Algorithm Algorithm // lean or mean
+	Params    Params    // graph size the instance solves
+	Threads   int       // threads working on each graph
+}
+
+// Phase is the time a backend spent in one phase of its last Solve, summed
+// over the graphs it solved
+type Phase struct {
+	Name     string
+	Duration time.Duration
+}
+
+// PhaseReporter is implemented by backends that time the phases of a solve
+type PhaseReporter interface {
+	LastPhases() []Phase
+}
+
+// BackendInfo registers a backend under Name
+type BackendInfo struct {
+	Name      string
+	Algorithm Algorithm
+	// MultiThreaded backends spread one graph over all threads; the others
+	// run best as one single-threaded instance per core
+	MultiThreaded bool
+	// Native backends run the C++ solvers through cgo
+	Native bool
+
+	// Sizes lists the graph parameters the backend can solve
+	Sizes func() []Params
+	// New creates an instance for params with threads per graph
+	New func(params Params, threads int) (Backend, error)
+	// Memory reports the bytes New(params, threads) will allocate
+	Memory func(params Params, threads int) (uint64, error)
+}
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]BackendInfo{}
+)
+
+// RegisterBackend makes a backend available by name. Registering the same
+// name twice panics.
+func RegisterBackend(info BackendInfo) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	if _, dup := backends[info.Name]; dup {
+		panic("solver: backend registered twice: " + info.Name)
+	}
+	backends[info.Name] = info
+}
+
+// LookupBackend returns the backend registered as name
+func LookupBackend(name string) (BackendInfo, error) {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	info, ok := backends[name]
+	if !ok {
+		return BackendInfo{}, fmt.Errorf("solver: unknown backend %q (have %v)", name, backendNames())
+	}
+	return info, nil
+}
+
+// Backends lists the registered backend names in sorted order
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	return backendNames()
+}
+
+func backendNames() []string {
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewBackend creates an instance of the named backend
+func NewBackend(name string, params Params, threads int) (Backend, error) {
+	info, err := LookupBackend(name)
+	if err != nil {
+		return nil, err
+	}
+	return info.New(params, threads)
+}
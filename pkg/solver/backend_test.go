@@ -0,0 +1,69 @@
+package solver
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackendRegistry(t *testing.T) {
+	names := Backends()
+	for _, want := range []string{DefaultBackend, "purego"} {
+		if _, err := LookupBackend(want); err != nil {
+			t.Fatalf("backend %q not registered (have %v)", want, names)
+		}
+	}
+	if _, err := LookupBackend("no-such-backend"); err == nil {
+		t.Error("expected error for unknown backend")
+	}
+
+	small := Params{EdgeBits: 19, ProofSize: 42}
+	for _, name := range names {
+		info, _ := LookupBackend(name)
+		if !supportsSize(info, small) {
+			continue
+		}
+		mem, err := info.Memory(small, 1)
+		if err != nil || mem == 0 {
+			t.Errorf("%s: Memory = %d, %v", name, mem, err)
+		}
+
+		b, err := NewBackend(name, small, 1)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		caps := b.Capabilities()
+		if caps.Backend != name || caps.Params != small || caps.Threads != 1 || caps.Algorithm != info.Algorithm {
+			t.Errorf("%s: unexpected capabilities %+v", name, caps)
+		}
+		if err := b.SetHeader(make([]byte, 80)); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if _, err := b.Solve(0, 1); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if pr, ok := b.(PhaseReporter); ok {
+			var total time.Duration
+			for _, ph := range pr.LastPhases() {
+				total += ph.Duration
+			}
+			if total <= 0 {
+				t.Errorf("%s: no phase times after Solve: %v", name, pr.LastPhases())
+			}
+		}
+		b.Close()
+	}
+
+	// A failed constructor must not hand back a typed nil
+	if b, err := NewBackend("purego", Params{EdgeBits: 17, ProofSize: 42}, 1); err == nil || b != nil {
+		t.Errorf("NewBackend with unsupported params = %v, %v", b, err)
+	}
+}
+
+func supportsSize(info BackendInfo, p Params) bool {
+	for _, sp := range info.Sizes() {
+		if sp == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,314 @@
+package solver
+
+import (
+	"math/bits"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// Same round count cuckoo_lean.cpp gives Tromp's lean solver
+	goLeanTrims = 14
+	// Longest path followed while looking for cycles
+	goLeanMaxPath = 8192
+	// Edges processed between checks of the abort flag
+	goLeanAbortStride = 1 << 16
+)
+
+// goLean is a pure-Go port of Tromp's lean solver. Edges are trimmed in
+// place using one liveness bit per edge and two degree bits per node, then
+// the few survivors are searched for cycles. It is much slower than the C++
+// solver but needs no C toolchain.
+type goLean struct {
+	params   Params
+	nthreads int
+	nedges   uint32 // 0 when edgebits is 32, which Validate rejects
+	edgeMask uint32
+
+	// Allocated on first solve and kept until release
+	alive []uint64 // edge still in the graph
+	once  []uint64 // node (index node>>1) has at least one live edge
+	twice []uint64 // node has at least two live edges
+
+	// Time spent in each phase of the last solve
+	setupTime, trimTime, searchTime time.Duration
+}
+
+func newGoLean(params Params, nthreads int) *goLean {
+	nedges := uint32(1) << params.EdgeBits
+	return &goLean{params: params, nthreads: nthreads, nedges: nedges, edgeMask: nedges - 1}
+}
+
+// goLeanMembytes mirrors the lean membytes in cuckoo_lean.cpp: three bits
+// per edge
+func goLeanMembytes(params Params) uint64 {
+	return (uint64(1) << params.EdgeBits) / 8 * 3
+}
+
+// goLeanAllocs and goLeanReleases count alloc and release of solver memory
+var goLeanAllocs, goLeanReleases atomic.Uint64
+
+func (g *goLean) alloc() {
+	if g.alive != nil {
+		return
+	}
+	words := (int(g.nedges) + 63) / 64
+	g.alive = make([]uint64, words)
+	g.once = make([]uint64, words)
+	g.twice = make([]uint64, words)
+	goLeanAllocs.Add(1)
+}
+
+// release drops the solver memory; the next solve allocates it again
+func (g *goLean) release() {
+	if g.alive != nil {
+		goLeanReleases.Add(1)
+	}
+	g.alive, g.once, g.twice = nil, nil, nil
+}
+
+// solve finds up to maxSols cycles of params.ProofSize edges in the graph
+// keyed by keys. When abort is raised it returns what it has found so far
+// and done is false.
+func (g *goLean) solve(keys *siphashKeys, abort *atomic.Bool, maxSols int) (sols [][]uint32, done bool) {
+	g.setupTime, g.trimTime, g.searchTime = 0, 0, 0
+	start := time.Now()
+	g.alloc()
+	for i := range g.alive {
+		g.alive[i] = ^uint64(0)
+	}
+	if tail := g.nedges % 64; tail != 0 {
+		g.alive[len(g.alive)-1] = 1<<tail - 1
+	}
+	trimStart := time.Now()
+	g.setupTime = trimStart.Sub(start)
+
+	for round := 0; round < goLeanTrims; round++ {
+		for uorv := uint32(0); uorv < 2; uorv++ {
+			if abort.Load() {
+				return nil, false
+			}
+			g.trim(keys, uorv, abort)
+		}
+		logDebug("purego: trimming round done", zap.Int("round", round))
+	}
+	searchStart := time.Now()
+	g.trimTime = searchStart.Sub(trimStart)
+	if abort.Load() {
+		return nil, false
+	}
+	sols, done = g.findCycles(keys, abort, maxSols)
+	g.searchTime = time.Since(searchStart)
+	return sols, done
+}
+
+// trim removes the edges whose endpoint on side uorv has no other live edge
+func (g *goLean) trim(keys *siphashKeys, uorv uint32, abort *atomic.Bool) {
+	clear(g.once)
+	clear(g.twice)
+
+	g.parallel(abort, func(e uint32) {
+		n := keys.sipnode(e, uorv, g.edgeMask) >> 1
+		if setBit(g.once, n) {
+			setBit(g.twice, n)
+		}
+	})
+	g.parallel(abort, func(e uint32) {
+		n := keys.sipnode(e, uorv, g.edgeMask) >> 1
+		if g.twice[n/64]&(1<<(n%64)) == 0 {
+			// Chunks are 64-edge aligned, so this word is ours alone
+			g.alive[e/64] &^= 1 << (e % 64)
+		}
+	})
+}
+
+// parallel calls fn for every live edge, splitting the edges into one
+// 64-aligned chunk per thread
+func (g *goLean) parallel(abort *atomic.Bool, fn func(e uint32)) {
+	words := uint32(len(g.alive))
+	per := (words + uint32(g.nthreads) - 1) / uint32(g.nthreads)
+
+	var wg sync.WaitGroup
+	for lo := uint32(0); lo < words; lo += per {
+		hi := min(lo+per, words)
+		wg.Add(1)
+		go func(lo, hi uint32) {
+			defer wg.Done()
+			for w := lo; w < hi; w++ {
+				if w%(goLeanAbortStride/64) == 0 && abort.Load() {
+					return
+				}
+				for word := g.alive[w]; word != 0; word &= word - 1 {
+					fn(w*64 + uint32(bits.TrailingZeros64(word)))
+				}
+			}
+		}(lo, hi)
+	}
+	wg.Wait()
+}
+
+// setBit sets bit i and reports whether it was already set
+func setBit(words []uint64, i uint32) bool {
+	p := &words[i/64]
+	mask := uint64(1) << (i % 64)
+	for {
+		old := atomic.LoadUint64(p)
+		if old&mask != 0 {
+			return true
+		}
+		if atomic.CompareAndSwapUint64(p, old, old|mask) {
+			return false
+		}
+	}
+}
+
+// findCycles runs Tromp's cuckoo path search over the trimmed edges
+func (g *goLean) findCycles(keys *siphashKeys, abort *atomic.Bool, maxSols int) (sols [][]uint32, done bool) {
+	cuckoo := make(map[uint32]uint32)
+	us := make([]uint32, goLeanMaxPath)
+	vs := make([]uint32, goLeanMaxPath)
+
+	visited := 0
+	for w, word := range g.alive {
+		for ; word != 0; word &= word - 1 {
+			if visited++; visited%goLeanAbortStride == 0 && abort.Load() {
+				return sols, false
+			}
+			e := uint32(w)*64 + uint32(bits.TrailingZeros64(word))
+			u0 := keys.sipnode(e, 0, g.edgeMask)
+			v0 := keys.sipnode(e, 1, g.edgeMask)
+			nu := cuckooPath(cuckoo, u0, us)
+			nv := cuckooPath(cuckoo, v0, vs)
+			if nu < 0 || nv < 0 {
+				logDebug("purego: maximum path length exceeded")
+				continue
+			}
+			if us[nu] == vs[nv] {
+				m := min(nu, nv)
+				nu, nv = nu-m, nv-m
+				for us[nu] != vs[nv] {
+					nu++
+					nv++
+				}
+				if nu+nv+1 == g.params.ProofSize {
+					if sol := g.recoverCycle(keys, us[:nu+1], vs[:nv+1]); sol != nil {
+						sols = append(sols, sol)
+						if len(sols) == maxSols {
+							return sols, true
+						}
+					}
+				}
+				continue
+			}
+			// Reverse the shorter path and link the new edge onto it
+			if nu < nv {
+				for i := nu - 1; i >= 0; i-- {
+					cuckoo[us[i+1]] = us[i]
+				}
+				cuckoo[u0] = v0
+			} else {
+				for i := nv - 1; i >= 0; i-- {
+					cuckoo[vs[i+1]] = vs[i]
+				}
+				cuckoo[v0] = u0
+			}
+		}
+	}
+	return sols, true
+}
+
+// cuckooPath follows the links from u, recording the nodes in path. It
+// returns the index of the last node, or -1 if the path is too long.
+func cuckooPath(cuckoo map[uint32]uint32, u uint32, path []uint32) int {
+	n := 0
+	path[0] = u
+	for {
+		next, ok := cuckoo[u]
+		if !ok {
+			return n
+		}
+		if n++; n >= len(path) {
+			return -1
+		}
+		path[n] = next
+		u = next
+	}
+}
+
+// recoverCycle finds the live edges on the cycle closed by us and vs,
+// returning them in ascending order
+func (g *goLean) recoverCycle(keys *siphashKeys, us, vs []uint32) []uint32 {
+	type edge struct{ u, v uint32 }
+	cycle := map[edge]bool{{us[0], vs[0]}: true}
+	// us alternates U,V,... from us[0]; vs alternates V,U,...
+	for i := len(us) - 2; i >= 0; i-- {
+		cycle[edge{us[(i+1)&^1], us[i|1]}] = true
+	}
+	for i := len(vs) - 2; i >= 0; i-- {
+		cycle[edge{vs[i|1], vs[(i+1)&^1]}] = true
+	}
+
+	sol := make([]uint32, 0, g.params.ProofSize)
+	for w, word := range g.alive {
+		for ; word != 0; word &= word - 1 {
+			e := uint32(w)*64 + uint32(bits.TrailingZeros64(word))
+			if cycle[edge{keys.sipnode(e, 0, g.edgeMask), keys.sipnode(e, 1, g.edgeMask)}] {
+				sol = append(sol, e)
+			}
+		}
+	}
+	if len(sol) != g.params.ProofSize {
+		return nil
+	}
+	return sol
+}
+
+// verifyProof checks that proof lists params.ProofSize ascending edges that
+// form a single cycle in the graph keyed by keys, like verify_proof in
+// solver/tromp/cuckoo_verify.h
+func verifyProof(params Params, keys *siphashKeys, proof []uint32) bool {
+	if len(proof) != params.ProofSize {
+		return false
+	}
+	edgeMask := uint32(1)<<params.EdgeBits - 1
+	uvs := make([]uint32, 2*len(proof))
+	var xor0, xor1 uint32
+	for n, e := range proof {
+		if e > edgeMask || (n > 0 && e <= proof[n-1]) {
+			return false
+		}
+		uvs[2*n] = keys.sipnode(e, 0, edgeMask)
+		uvs[2*n+1] = keys.sipnode(e, 1, edgeMask)
+		xor0 ^= uvs[2*n]
+		xor1 ^= uvs[2*n+1]
+	}
+	if xor0|xor1 != 0 {
+		return false
+	}
+
+	// Walk the cycle; every node must have exactly one other edge
+	n, i := 0, 0
+	for {
+		j := i
+		for k := (i + 2) % len(uvs); k != i; k = (k + 2) % len(uvs) {
+			if uvs[k] == uvs[i] {
+				if j != i {
+					return false
+				}
+				j = k
+			}
+		}
+		if j == i {
+			return false
+		}
+		i = j ^ 1
+		n++
+		if i == 0 {
+			break
+		}
+	}
+	return n == params.ProofSize
+}
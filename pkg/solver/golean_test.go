@@ -0,0 +1,58 @@
+package solver
+
+import (
+	"encoding/binary"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestGoLeanFindsCycles runs the pure-Go engine on small graphs with short
+// cycles, where solutions are common, and checks them with verifyProof
+func TestGoLeanFindsCycles(t *testing.T) {
+	params := Params{EdgeBits: 12, ProofSize: 6}
+	g := newGoLean(params, 2)
+	var abort atomic.Bool
+
+	found := 0
+	header := make([]byte, 80)
+	for i := uint32(0); i < 200; i++ {
+		binary.LittleEndian.PutUint32(header, i)
+		keys := newSiphashKeys(headerKey(header))
+		sols, done := g.solve(&keys, &abort, MaxSols)
+		if !done {
+			t.Fatalf("header %d: solve did not finish", i)
+		}
+		for _, sol := range sols {
+			found++
+			if !verifyProof(params, &keys, sol) {
+				t.Fatalf("header %d: solution %v failed verification", i, sol)
+			}
+			bad := append([]uint32(nil), sol...)
+			bad[0]++
+			if bad[0] < bad[1] && verifyProof(params, &keys, bad) {
+				t.Fatalf("header %d: tampered solution %v verified", i, bad)
+			}
+		}
+	}
+	if found == 0 {
+		t.Fatal("no cycles found in 200 graphs")
+	}
+	t.Logf("found %d cycles", found)
+}
+
+func TestGoSolverFinalizer(t *testing.T) {
+	before := liveSolvers.Load()
+	if _, err := NewGoSolver(Params{EdgeBits: 19, ProofSize: 42}, 1); err != nil {
+		t.Fatal(err)
+	}
+	// Dropped without Close; the finalizer releases it
+	for i := 0; i < 50 && liveSolvers.Load() != before; i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := liveSolvers.Load(); n != before {
+		t.Errorf("%d GoSolvers open after GC, want %d", n, before)
+	}
+}
@@ -0,0 +1,246 @@
+package solver
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// goLeanSizes are the graph sizes the pure-Go solver accepts; the same ones
+// the C library builds the lean solver for
+var goLeanSizes = []Params{
+	{EdgeBits: 19, ProofSize: 42},
+	{EdgeBits: 23, ProofSize: 42},
+	{EdgeBits: 29, ProofSize: 42},
+	{EdgeBits: 31, ProofSize: 42},
+}
+
+// liveSolvers counts GoSolvers between NewGoSolver and Close
+var liveSolvers atomic.Int64
+
+// GoSolver runs the pure-Go lean solver. It is the Solver of builds without
+// cgo and the "purego" backend of all builds; far slower than the C++ one.
+//
+// The graph is keyed by the header alone, as in the cgo wrappers, so every
+// nonce of a range yields the same graph: Solve trims it once and reports
+// each cycle once. A GoSolver runs one Solve at a time; Cancel may be called
+// from any goroutine.
+type GoSolver struct {
+	engine   *goLean
+	keys     siphashKeys
+	hasKeys  bool
+	params   Params
+	nthreads int
+	graphs   int // searched by the last Solve; guarded by mu
+
+	// mu serialises SetHeader/Solve/Close on the engine
+	mu     sync.Mutex
+	abort  atomic.Bool
+	busy   atomic.Bool
+	closed atomic.Bool
+}
+
+// NewGoSolver creates a pure-Go solver for params using nthreads threads
+// per graph. The parameters must be one of GoSolverParams.
+func NewGoSolver(params Params, nthreads int) (*GoSolver, error) {
+	if nthreads < 1 {
+		return nil, fmt.Errorf("solver: invalid thread count %d", nthreads)
+	}
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	if !goSupported(params) {
+		return nil, fmt.Errorf("solver: no purego solver for %s (have %v)", params, GoSolverParams())
+	}
+	liveSolvers.Add(1)
+	s := &GoSolver{
+		engine:   newGoLean(params, nthreads),
+		params:   params,
+		nthreads: nthreads,
+	}
+	// Safety net for solvers that are dropped without Close
+	runtime.SetFinalizer(s, (*GoSolver).Close)
+	return s, nil
+}
+
+// SetHeader sets the header data for mining
+func (s *GoSolver) SetHeader(header []byte) error {
+	if len(header) > 80 {
+		header = header[:80]
+	}
+	if len(header) == 0 {
+		panic("SetHeader: empty header")
+	}
+	if !s.busy.CompareAndSwap(false, true) {
+		return ErrBusy
+	}
+	defer s.busy.Store(false)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed.Load() {
+		return ErrClosed
+	}
+	s.keys = newSiphashKeys(headerKey(header))
+	s.hasKeys = true
+	return nil
+}
+
+// Solve searches for Cuckoo cycles in the given nonce range
+func (s *GoSolver) Solve(baseNonce uint32, nonceRange uint32) ([]Solution, error) {
+	return s.SolveContext(context.Background(), baseNonce, nonceRange)
+}
+
+// SolveContext is like Solve but aborts the search as soon as ctx is done.
+// Solutions found before the abort are returned together with ctx.Err().
+func (s *GoSolver) SolveContext(ctx context.Context, baseNonce uint32, nonceRange uint32) ([]Solution, error) {
+	if !s.busy.CompareAndSwap(false, true) {
+		return nil, ErrBusy
+	}
+	defer s.busy.Store(false)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Same ordering as the cgo Solver: reset first, then look at closed/ctx
+	s.abort.Store(false)
+	s.graphs = 0
+	if s.closed.Load() {
+		return nil, ErrClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !s.hasKeys || nonceRange == 0 {
+		return nil, nil
+	}
+	stop := context.AfterFunc(ctx, s.Cancel)
+	defer stop()
+
+	logDebug("purego: solving",
+		zap.Int("edgebits", s.params.EdgeBits), zap.Int("nthreads", s.nthreads),
+		zap.Uint32("nonce", baseNonce), zap.Uint32("range", nonceRange))
+
+	cycles, done := s.engine.solve(&s.keys, &s.abort, MaxSols)
+	if done {
+		s.graphs = 1
+	}
+	solutions := make([]Solution, 0, len(cycles))
+	for _, c := range cycles {
+		solutions = append(solutions, Solution{Nonce: c})
+	}
+	return solutions, ctx.Err()
+}
+
+// Cancel aborts the Solve in progress, if any. It is safe to call from any
+// goroutine, including concurrently with Close.
+func (s *GoSolver) Cancel() {
+	s.abort.Store(true)
+}
+
+// LastGraphs reports how many graphs the last Solve searched to the end.
+// The pure-Go solver searches at most one graph per Solve.
+func (s *GoSolver) LastGraphs() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.graphs
+}
+
+// LastPhases reports where the last Solve spent its time
+func (s *GoSolver) LastPhases() []Phase {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return []Phase{
+		{Name: "setup", Duration: s.engine.setupTime},
+		{Name: "trim", Duration: s.engine.trimTime},
+		{Name: "search", Duration: s.engine.searchTime},
+	}
+}
+
+// Params returns the graph parameters the solver was built for
+func (s *GoSolver) Params() Params {
+	return s.params
+}
+
+// Algorithm returns which solver the Solver runs; always Lean
+func (s *GoSolver) Algorithm() Algorithm {
+	return Lean
+}
+
+// Capabilities describes the solver for the backend registry
+func (s *GoSolver) Capabilities() Capabilities {
+	return Capabilities{Backend: "purego", Algorithm: Lean, Params: s.params, Threads: s.nthreads}
+}
+
+// GoSolverParams lists the graph parameters the pure-Go solver accepts
+func GoSolverParams() []Params {
+	return append([]Params(nil), goLeanSizes...)
+}
+
+func goSupported(p Params) bool {
+	for _, sp := range goLeanSizes {
+		if sp == p {
+			return true
+		}
+	}
+	return false
+}
+
+// goVerify checks a solution against the graph the pure-Go solver builds
+// for header
+func goVerify(params Params, header []byte, proof []uint32) bool {
+	if len(proof) != params.ProofSize || len(header) == 0 || !goSupported(params) {
+		return false
+	}
+	if len(header) > 80 {
+		header = header[:80]
+	}
+	keys := newSiphashKeys(headerKey(header))
+	return verifyProof(params, &keys, proof)
+}
+
+// GetStats returns solver statistics
+func (s *GoSolver) GetStats() string {
+	return fmt.Sprintf("Solver: lean (pure Go), %d threads, EdgeBits: %d, ProofSize: %d",
+		s.nthreads, s.params.EdgeBits, s.params.ProofSize)
+}
+
+// Close releases the solver memory. A Solve running on another goroutine is
+// cancelled and waited for. Close is idempotent.
+func (s *GoSolver) Close() {
+	if s.closed.Swap(true) {
+		return
+	}
+	runtime.SetFinalizer(s, nil)
+
+	s.Cancel()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.engine.release()
+	liveSolvers.Add(-1)
+}
+
+func init() {
+	RegisterBackend(BackendInfo{
+		Name:      "purego",
+		Algorithm: Lean,
+		Sizes:     GoSolverParams,
+		New: func(params Params, threads int) (Backend, error) {
+			s, err := NewGoSolver(params, threads)
+			if err != nil {
+				return nil, err
+			}
+			return s, nil
+		},
+		Memory: func(params Params, threads int) (uint64, error) {
+			if !goSupported(params) {
+				return 0, fmt.Errorf("solver: no purego solver for %s", params)
+			}
+			return goLeanMembytes(params), nil
+		},
+	})
+}
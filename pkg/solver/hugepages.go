@@ -0,0 +1,72 @@
+package solver
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// HugePages selects how the C solvers back their large buffers
+type HugePages int
+
+const (
+	// HugePagesOff allocates with plain new/malloc
+	HugePagesOff HugePages = iota
+	// HugePagesTHP maps 2 MiB aligned memory advised for transparent huge
+	// pages; whether the kernel grants them depends on
+	// /sys/kernel/mm/transparent_hugepage
+	HugePagesTHP
+	// HugePagesHugeTLB takes pages reserved with vm.nr_hugepages, falling
+	// back to THP when the pool runs dry
+	HugePagesHugeTLB
+)
+
+func (h HugePages) String() string {
+	switch h {
+	case HugePagesOff:
+		return "off"
+	case HugePagesTHP:
+		return "thp"
+	case HugePagesHugeTLB:
+		return "hugetlb"
+	}
+	return fmt.Sprintf("HugePages(%d)", int(h))
+}
+
+// ParseHugePages returns the HugePages mode named s ("off", "thp" or
+// "hugetlb")
+func ParseHugePages(s string) (HugePages, error) {
+	for _, h := range []HugePages{HugePagesOff, HugePagesTHP, HugePagesHugeTLB} {
+		if s == h.String() {
+			return h, nil
+		}
+	}
+	return 0, fmt.Errorf("solver: unknown huge pages mode %q", s)
+}
+
+// HugePageStatus reports how much of a solver's memory is on huge pages
+type HugePageStatus struct {
+	Mode    HugePages
+	Bytes   uint64 // buffers allocated under Mode; zero when off
+	HugeTLB uint64 // of which on hugetlb pages
+	THP     uint64 // of which on transparent huge pages right now
+}
+
+// Huge is the number of bytes actually backed by huge pages
+func (s HugePageStatus) Huge() uint64 {
+	return s.HugeTLB + s.THP
+}
+
+// HugePageReporter is implemented by backends that can back their memory
+// with huge pages
+type HugePageReporter interface {
+	HugePages() HugePageStatus
+}
+
+// defaultHugePages is what registry backends are created with
+var defaultHugePages atomic.Int32
+
+// SetHugePages sets the huge page mode of C solvers created through the
+// backend registry from now on. The pure-Go solver ignores it.
+func SetHugePages(h HugePages) {
+	defaultHugePages.Store(int32(h))
+}
@@ -0,0 +1,37 @@
+package solver
+
+import (
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// solverLogger receives diagnostics emitted by the solver
+var solverLogger atomic.Pointer[zap.Logger]
+
+// SetLogger routes solver diagnostics to logger. Only levels the logger has
+// enabled are forwarded, so the solve loop stays silent unless the logger
+// runs at Debug; SyncLogLevel picks up a later change of level. A nil logger silences the solver (the default).
+func SetLogger(logger *zap.Logger) {
+	if logger == nil {
+		setNativeLogger(nil)
+		solverLogger.Store(nil)
+		return
+	}
+	solverLogger.Store(logger)
+	setNativeLogger(logger)
+}
+
+// SyncLogLevel passes a change in the solver logger's level, such as a
+// zap.AtomicLevel set at run time, on to the C++ solvers. They check the
+// level before formatting a message, so they only learn of it this way.
+func SyncLogLevel() {
+	setNativeLogger(solverLogger.Load())
+}
+
+// logDebug emits a diagnostic from the Go side of the solver
+func logDebug(msg string, fields ...zap.Field) {
+	if logger := solverLogger.Load(); logger != nil {
+		logger.Debug(msg, fields...)
+	}
+}
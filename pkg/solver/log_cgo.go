@@ -0,0 +1,58 @@
+//go:build cgo && !purego
+
+package solver
+
+/*
+#include "cuckoo_lean.h"
+
+extern void goCuckooLog(int level, char* msg);
+*/
+import "C"
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// setNativeLogger points the C++ solver's diagnostics at solverLogger, at
+// the lowest level logger has enabled now
+func setNativeLogger(logger *zap.Logger) {
+	if logger == nil {
+		C.cuckoo_set_logger(nil, C.CUCKOO_LOG_NONE)
+		return
+	}
+	C.cuckoo_set_logger(C.cuckoo_log_fn(C.goCuckooLog), C.int(minLogLevel(logger.Core())))
+}
+
+// minLogLevel maps the lowest zap level enabled on core to a CUCKOO_LOG_* value
+func minLogLevel(core zapcore.Core) int {
+	switch {
+	case core.Enabled(zapcore.DebugLevel):
+		return C.CUCKOO_LOG_DEBUG
+	case core.Enabled(zapcore.InfoLevel):
+		return C.CUCKOO_LOG_INFO
+	case core.Enabled(zapcore.WarnLevel):
+		return C.CUCKOO_LOG_WARN
+	case core.Enabled(zapcore.ErrorLevel):
+		return C.CUCKOO_LOG_ERROR
+	}
+	return C.CUCKOO_LOG_NONE
+}
+
+//export goCuckooLog
+func goCuckooLog(level C.int, msg *C.char) {
+	logger := solverLogger.Load()
+	if logger == nil {
+		return
+	}
+	text := C.GoString(msg)
+	switch level {
+	case C.CUCKOO_LOG_DEBUG:
+		logger.Debug(text)
+	case C.CUCKOO_LOG_INFO:
+		logger.Info(text)
+	case C.CUCKOO_LOG_WARN:
+		logger.Warn(text)
+	default:
+		logger.Error(text)
+	}
+}
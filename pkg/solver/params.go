@@ -0,0 +1,86 @@
+package solver
+
+import "fmt"
+
+// MaxSols is the most solutions a single Solve call reports
+const MaxSols = 8
+
+// MaxProofSize is the longest cycle any solver build can report
+const MaxProofSize = 64
+
+// Params describes the Cuckoo graph a solver works on
+type Params struct {
+	EdgeBits  int // log2 of the number of edges
+	ProofSize int // cycle length
+}
+
+// DefaultParams matches the Java reference miner
+var DefaultParams = Params{EdgeBits: 23, ProofSize: 42}
+
+// Validate checks that p describes a well-formed graph. Whether a solver was
+// built for it is checked separately by NewSolver.
+func (p Params) Validate() error {
+	if p.EdgeBits < 1 || p.EdgeBits > 31 {
+		return fmt.Errorf("solver: edge bits %d out of range [1, 31]", p.EdgeBits)
+	}
+	if p.ProofSize < 2 || p.ProofSize > MaxProofSize || p.ProofSize%2 != 0 {
+		return fmt.Errorf("solver: proof size %d must be even and in [2, %d]", p.ProofSize, MaxProofSize)
+	}
+	return nil
+}
+
+func (p Params) String() string {
+	return fmt.Sprintf("e%dp%d", p.EdgeBits, p.ProofSize)
+}
+
+// Algorithm selects which of Tromp's solvers a Solver runs
+type Algorithm int
+
+const (
+	// Lean needs little memory (about 3 bits per edge) and is best run as one
+	// single-threaded graph per core
+	Lean Algorithm = iota
+	// Mean buckets edges in several GB of memory and trims one graph with
+	// many threads; much faster on big-core machines with RAM to spare
+	Mean
+)
+
+func (a Algorithm) String() string {
+	switch a {
+	case Lean:
+		return "lean"
+	case Mean:
+		return "mean"
+	}
+	return fmt.Sprintf("Algorithm(%d)", int(a))
+}
+
+// ParseAlgorithm returns the Algorithm named s ("lean" or "mean")
+func ParseAlgorithm(s string) (Algorithm, error) {
+	switch s {
+	case "lean":
+		return Lean, nil
+	case "mean":
+		return Mean, nil
+	}
+	return 0, fmt.Errorf("solver: unknown algorithm %q", s)
+}
+
+// Options configures a new Solver
+type Options struct {
+	Algorithm Algorithm
+	Params    Params    // zero value means DefaultParams
+	Threads   int       // threads working on each graph; zero means 1
+	HugePages HugePages // how the C solvers back their buffers
+}
+
+// withDefaults fills in zero fields
+func (o Options) withDefaults() Options {
+	if o.Params == (Params{}) {
+		o.Params = DefaultParams
+	}
+	if o.Threads == 0 {
+		o.Threads = 1
+	}
+	return o
+}
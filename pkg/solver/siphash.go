@@ -0,0 +1,57 @@
+package solver
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// siphashKeys are the four siphash-2-4 keys a graph is built from, read
+// little-endian from the 32-byte header key like Tromp's setkeys
+type siphashKeys [4]uint64
+
+func newSiphashKeys(key [32]byte) siphashKeys {
+	return siphashKeys{
+		binary.LittleEndian.Uint64(key[0:8]),
+		binary.LittleEndian.Uint64(key[8:16]),
+		binary.LittleEndian.Uint64(key[16:24]),
+		binary.LittleEndian.Uint64(key[24:32]),
+	}
+}
+
+// siphash24 hashes one 64-bit word, as in Tromp's siphash.hpp
+func (k *siphashKeys) siphash24(nonce uint64) uint64 {
+	v0, v1, v2, v3 := k[0], k[1], k[2], k[3]^nonce
+	v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+	v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+	v0 ^= nonce
+	v2 ^= 0xff
+	v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+	v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+	v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+	v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+	return v0 ^ v1 ^ v2 ^ v3
+}
+
+// sipnode maps edge to its endpoint on side uorv (0 = U, 1 = V). Nodes of
+// both sides share one number space, told apart by the low bit.
+func (k *siphashKeys) sipnode(edge uint32, uorv uint32, edgeMask uint32) uint32 {
+	return (uint32(k.siphash24(2*uint64(edge)+uint64(uorv)))&edgeMask)<<1 | uorv
+}
+
+func sipRound(v0, v1, v2, v3 uint64) (uint64, uint64, uint64, uint64) {
+	v0 += v1
+	v2 += v3
+	v1 = bits.RotateLeft64(v1, 13)
+	v3 = bits.RotateLeft64(v3, 16)
+	v1 ^= v0
+	v3 ^= v2
+	v0 = bits.RotateLeft64(v0, 32)
+	v2 += v1
+	v0 += v3
+	v1 = bits.RotateLeft64(v1, 17)
+	v3 = bits.RotateLeft64(v3, 21)
+	v1 ^= v2
+	v3 ^= v0
+	v2 = bits.RotateLeft64(v2, 32)
+	return v0, v1, v2, v3
+}
@@ -0,0 +1,64 @@
+// Package solver provides Go interface to Cuckoo Cycle solver.
+//
+// By default it wraps the C++ solvers in solver/tromp through cgo. Builds
+// without cgo, or with the purego tag, get a pure-Go lean solver with the
+// same API instead; it is slow but needs no C toolchain.
+package solver
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+)
+
+// Solution represents a Cuckoo Cycle solution
+type Solution struct {
+	Nonce []uint32
+}
+
+var (
+	// ErrClosed is returned when a Solver is used after Close
+	ErrClosed = errors.New("solver: closed")
+	// ErrBusy is returned when a Solver is already running a Solve
+	ErrBusy = errors.New("solver: solve already in progress")
+)
+
+// headerKey derives the 32-byte siphash key for a header as SHA256d(header),
+// matching Java reference
+func headerKey(header []byte) [32]byte {
+	h1 := sha256.Sum256(header)
+	return sha256.Sum256(h1[:])
+}
+
+// HashSolution computes SHA256d hash of the solution for difficulty check
+func HashSolution(header []byte, nonce uint32, solution []uint32) [32]byte {
+	// Build data: header + nonce + solution
+	data := make([]byte, len(header)+4+len(solution)*4)
+	copy(data, header)
+	binary.LittleEndian.PutUint32(data[len(header):], nonce)
+
+	offset := len(header) + 4
+	for _, s := range solution {
+		binary.LittleEndian.PutUint32(data[offset:], s)
+		offset += 4
+	}
+
+	// SHA256d
+	h1 := sha256.Sum256(data)
+	h2 := sha256.Sum256(h1[:])
+	return h2
+}
+
+// CheckDifficulty checks if solution hash meets target difficulty
+func CheckDifficulty(hash [32]byte, target []byte) bool {
+	// Compare as big-endian (Bitcoin style)
+	for i := 0; i < len(target) && i < 32; i++ {
+		if hash[31-i] > target[31-i] {
+			return false
+		}
+		if hash[31-i] < target[31-i] {
+			return true
+		}
+	}
+	return true
+}
@@ -1,4 +1,5 @@
-// Package solver provides Go interface to Cuckoo Cycle solver
+//go:build cgo && !purego
+
 package solver
 
 /*
@@ -7,70 +8,130 @@ package solver
 #cgo LDFLAGS: -L../../solver/tromp -lcuckoo_lean -lstdc++ -lpthread
 
 #include "cuckoo_lean.h"
-#include <stdlib.h>
-// Allocate/free helpers with external linkage for cgo
-solver_ctx* alloc_solver_ctx() { return (solver_ctx*)malloc(sizeof(solver_ctx)); }
-void free_solver_ctx(solver_ctx* p) { if (p) free(p); }
-// Forward declarations for new APIs
-void cuckoo_sethdrkey(solver_ctx* ctx, const uint8_t* key32);
-// Forward declaration and wrapper for abort to satisfy cgo
-void cuckoo_abort(solver_ctx* ctx);
-void go_cuckoo_abort(solver_ctx* ctx) { cuckoo_abort(ctx); }
 */
 import "C"
 import (
-	"crypto/sha256"
-	"encoding/binary"
+	"context"
+	"errors"
 	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
-const (
-	ProofSize = 42
-	MaxSols   = 8
-	EdgeBits  = 23
-)
-
-// Solution represents a Cuckoo Cycle solution
-type Solution struct {
-	Nonce []uint32
-}
-
-// Solver wraps the C++ Cuckoo solver
+// Solver wraps the C++ Cuckoo solver.
+//
+// The C context (and the solver memory hanging off it) is owned by the
+// Solver from NewSolver until Close. A Solver runs one Solve at a time;
+// Cancel may be called from any goroutine.
 type Solver struct {
-	ctx      C.solver_ctx
+	ctx      *C.solver_ctx
+	algo     Algorithm
+	params   Params
 	nthreads int
+	// hugePages is the mode requested; HugePages reports how much of the
+	// solver's memory the kernel actually backed with huge pages
+	hugePages HugePages
+	phases    []Phase // of the last Solve; guarded by mu
+	graphs    int     // searched by the last Solve; guarded by mu
+
+	// mu serialises SetHeader/Solve/Close on the C context
+	mu sync.Mutex
+	// ctxMu guards the ctx pointer itself so Cancel never touches freed memory
+	ctxMu  sync.RWMutex
+	busy   atomic.Bool
+	closed atomic.Bool
 }
 
-// NewSolver creates a new Cuckoo solver with specified threads
-func NewSolver(nthreads int) *Solver {
-	s := &Solver{nthreads: nthreads}
-	// Initialize C context in-place
-	C.cuckoo_init(&s.ctx)
-	s.ctx.nthreads = C.uint32_t(nthreads)
-	return s
+// NewSolver creates a new Cuckoo solver running opts.Algorithm on the given
+// graph parameters with opts.Threads threads per graph. The parameters must
+// be one of SupportedParams for that algorithm.
+func NewSolver(opts Options) (*Solver, error) {
+	opts = opts.withDefaults()
+	if err := checkOptions(opts); err != nil {
+		return nil, err
+	}
+	ctx := C.cuckoo_alloc()
+	if ctx == nil {
+		return nil, errors.New("solver: failed to allocate C context")
+	}
+	ctx.nthreads = C.uint32_t(opts.Threads)
+	ctx.backend = C.uint32_t(opts.Algorithm)
+	ctx.edgebits = C.uint32_t(opts.Params.EdgeBits)
+	ctx.proofsize = C.uint32_t(opts.Params.ProofSize)
+	ctx.hugepages = C.uint32_t(opts.HugePages)
+
+	s := &Solver{ctx: ctx, algo: opts.Algorithm, params: opts.Params, nthreads: opts.Threads, hugePages: opts.HugePages}
+	// Safety net for solvers that are dropped without Close
+	runtime.SetFinalizer(s, (*Solver).Close)
+	return s, nil
 }
 
 // SetHeader sets the header data for mining
-func (s *Solver) SetHeader(header []byte) {
+func (s *Solver) SetHeader(header []byte) error {
 	if len(header) > 80 {
 		header = header[:80]
 	}
 	if len(header) == 0 {
 		panic("SetHeader: empty header")
 	}
-	// Derive 32-byte key as SHA256d(header), matching Java reference
-	h1 := sha256.Sum256(header)
-	h2 := sha256.Sum256(h1[:])
-	C.cuckoo_sethdrkey(&s.ctx, (*C.uint8_t)(unsafe.Pointer(&h2[0])))
+	if !s.busy.CompareAndSwap(false, true) {
+		return ErrBusy
+	}
+	defer s.busy.Store(false)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed.Load() {
+		return ErrClosed
+	}
+
+	key := headerKey(header)
+	C.cuckoo_sethdrkey(s.ctx, (*C.uint8_t)(unsafe.Pointer(&key[0])))
+	return nil
 }
 
 // Solve searches for Cuckoo cycles in the given nonce range
-func (s *Solver) Solve(baseNonce uint32, nonceRange uint32) []Solution {
+func (s *Solver) Solve(baseNonce uint32, nonceRange uint32) ([]Solution, error) {
+	return s.SolveContext(context.Background(), baseNonce, nonceRange)
+}
+
+// SolveContext is like Solve but aborts the search as soon as ctx is done.
+// Solutions found before the abort are returned together with ctx.Err().
+func (s *Solver) SolveContext(ctx context.Context, baseNonce uint32, nonceRange uint32) ([]Solution, error) {
+	if !s.busy.CompareAndSwap(false, true) {
+		return nil, ErrBusy
+	}
+	defer s.busy.Store(false)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Clear stale aborts before looking at closed/ctx: a Close or cancel that
+	// races with us either lands after the reset or is caught by the checks
+	C.cuckoo_reset_abort(s.ctx)
+	s.graphs = 0
+	if s.closed.Load() {
+		return nil, ErrClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, s.Cancel)
+	defer stop()
+
 	s.ctx.nonce = C.uint32_t(baseNonce)
 	s.ctx.nonce_range = C.uint32_t(nonceRange)
 
-	nsols := int(C.cuckoo_solve(&s.ctx))
+	nsols := int(C.cuckoo_solve(s.ctx))
+	s.graphs = int(s.ctx.graphs)
+	s.phases = []Phase{
+		{Name: "setup", Duration: time.Duration(s.ctx.phase_ns[C.CUCKOO_PHASE_SETUP])},
+		// Tromp's solvers trim and search in one call
+		{Name: "trim+search", Duration: time.Duration(s.ctx.phase_ns[C.CUCKOO_PHASE_SOLVE])},
+	}
 
 	solutions := make([]Solution, 0, nsols)
 
@@ -79,36 +140,140 @@ func (s *Solver) Solve(baseNonce uint32, nonceRange uint32) []Solution {
 
 	for i := 0; i < nsols; i++ {
 		sol := Solution{
-			Nonce: make([]uint32, ProofSize),
+			Nonce: make([]uint32, s.params.ProofSize),
 		}
 		// Access nonce array in each proof
-		noncePtr := (*[ProofSize]C.uint32_t)(unsafe.Pointer(&proofsPtr[i].nonce[0]))
-		for j := 0; j < ProofSize; j++ {
+		noncePtr := (*[MaxProofSize]C.uint32_t)(unsafe.Pointer(&proofsPtr[i].nonce[0]))
+		for j := 0; j < s.params.ProofSize; j++ {
 			sol.Nonce[j] = uint32(noncePtr[j])
 		}
 		solutions = append(solutions, sol)
 	}
 
-	return solutions
+	return solutions, ctx.Err()
 }
 
-// Cancel requests to abort a running solve (best effort).
+// Cancel aborts the Solve in progress, if any. It is safe to call from any
+// goroutine, including concurrently with Close.
 func (s *Solver) Cancel() {
-	C.go_cuckoo_abort(&s.ctx)
+	s.ctxMu.RLock()
+	defer s.ctxMu.RUnlock()
+	if s.ctx == nil {
+		return
+	}
+	C.cuckoo_abort(s.ctx)
+}
+
+// LastGraphs reports how many graphs the last Solve searched to the end.
+// Graphs cut short by Cancel are not counted.
+func (s *Solver) LastGraphs() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.graphs
+}
+
+// LastPhases reports where the last Solve spent its time
+func (s *Solver) LastPhases() []Phase {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Phase(nil), s.phases...)
+}
+
+// HugePages reports how much of the solver memory is on huge pages. The
+// memory is allocated by the first Solve; before that all counts are zero.
+// Safe to call while a Solve is running.
+func (s *Solver) HugePages() HugePageStatus {
+	st := HugePageStatus{Mode: s.hugePages}
+	s.ctxMu.RLock()
+	defer s.ctxMu.RUnlock()
+	if s.ctx == nil {
+		return st
+	}
+	var info C.cuckoo_hugepage_info
+	C.cuckoo_hugepages(s.ctx, &info)
+	st.Bytes = uint64(info.large_bytes)
+	st.HugeTLB = uint64(info.hugetlb_bytes)
+	st.THP = uint64(info.thp_bytes)
+	return st
+}
+
+// Params returns the graph parameters the solver was built for
+func (s *Solver) Params() Params {
+	return s.params
 }
 
-// Verify checks if a solution is valid
-func Verify(header []byte, nonce uint32, proof []uint32) bool {
-	if len(proof) != ProofSize {
+// Algorithm returns which solver the Solver runs
+func (s *Solver) Algorithm() Algorithm {
+	return s.algo
+}
+
+// Capabilities describes the solver for the backend registry
+func (s *Solver) Capabilities() Capabilities {
+	return Capabilities{Backend: cgoBackendName(s.algo), Algorithm: s.algo, Params: s.params, Threads: s.nthreads}
+}
+
+// SupportedParams lists the graph parameters compiled into the C library for
+// an algorithm
+func SupportedParams(algo Algorithm) []Params {
+	n := int(C.cuckoo_variant_count())
+	out := make([]Params, 0, n)
+	for i := 0; i < n; i++ {
+		var be, eb, ps C.uint32_t
+		if C.cuckoo_variant_params(C.int(i), &be, &eb, &ps) != 0 && Algorithm(be) == algo {
+			out = append(out, Params{EdgeBits: int(eb), ProofSize: int(ps)})
+		}
+	}
+	return out
+}
+
+func supported(algo Algorithm, p Params) bool {
+	return C.cuckoo_supported(C.uint32_t(algo), C.uint32_t(p.EdgeBits), C.uint32_t(p.ProofSize)) != 0
+}
+
+// checkOptions rejects options no built solver can serve
+func checkOptions(opts Options) error {
+	if opts.Threads < 1 {
+		return fmt.Errorf("solver: invalid thread count %d", opts.Threads)
+	}
+	if err := opts.Params.Validate(); err != nil {
+		return err
+	}
+	if !supported(opts.Algorithm, opts.Params) {
+		return fmt.Errorf("solver: no %s solver built for %s (have %v)",
+			opts.Algorithm, opts.Params, SupportedParams(opts.Algorithm))
+	}
+	return nil
+}
+
+// MemoryRequired reports how many bytes of solver memory NewSolver(opts)
+// will allocate, without allocating it
+func MemoryRequired(opts Options) (uint64, error) {
+	opts = opts.withDefaults()
+	if err := checkOptions(opts); err != nil {
+		return 0, err
+	}
+	n := C.cuckoo_membytes(C.uint32_t(opts.Algorithm), C.uint32_t(opts.Params.EdgeBits),
+		C.uint32_t(opts.Params.ProofSize), C.uint32_t(opts.Threads))
+	return uint64(n), nil
+}
+
+// Verify checks if a solution is valid for a graph with the given parameters
+func Verify(params Params, header []byte, nonce uint32, proof []uint32) bool {
+	if len(proof) != params.ProofSize || len(header) == 0 {
+		return false
+	}
+	if !supported(Lean, params) && !supported(Mean, params) {
 		return false
 	}
 
-	cProof := make([]C.uint32_t, ProofSize)
+	cProof := make([]C.uint32_t, params.ProofSize)
 	for i, p := range proof {
 		cProof[i] = C.uint32_t(p)
 	}
 
 	result := C.cuckoo_verify(
+		C.uint32_t(params.EdgeBits),
+		C.uint32_t(params.ProofSize),
 		(*C.uint8_t)(unsafe.Pointer(&header[0])),
 		C.uint32_t(len(header)),
 		C.uint32_t(nonce),
@@ -118,44 +283,70 @@ func Verify(header []byte, nonce uint32, proof []uint32) bool {
 	return result != 0
 }
 
-// HashSolution computes SHA256d hash of the solution for difficulty check
-func HashSolution(header []byte, nonce uint32, solution []uint32) [32]byte {
-	// Build data: header + nonce + solution
-	data := make([]byte, len(header)+4+len(solution)*4)
-	copy(data, header)
-	binary.LittleEndian.PutUint32(data[len(header):], nonce)
+// GetStats returns solver statistics
+func (s *Solver) GetStats() string {
+	return fmt.Sprintf("Solver: %s, %d threads, EdgeBits: %d, ProofSize: %d",
+		s.algo, s.nthreads, s.params.EdgeBits, s.params.ProofSize)
+}
 
-	offset := len(header) + 4
-	for _, s := range solution {
-		binary.LittleEndian.PutUint32(data[offset:], s)
-		offset += 4
+// Close releases C-side resources for the solver context. A Solve running
+// on another goroutine is cancelled and waited for. Close is idempotent.
+func (s *Solver) Close() {
+	if s.closed.Swap(true) {
+		return
 	}
+	runtime.SetFinalizer(s, nil)
+
+	s.Cancel()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	// SHA256d
-	h1 := sha256.Sum256(data)
-	h2 := sha256.Sum256(h1[:])
-	return h2
+	s.ctxMu.Lock()
+	C.cuckoo_free(s.ctx)
+	s.ctx = nil
+	s.ctxMu.Unlock()
 }
 
-// CheckDifficulty checks if solution hash meets target difficulty
-func CheckDifficulty(hash [32]byte, target []byte) bool {
-	// Compare as big-endian (Bitcoin style)
-	for i := 0; i < len(target) && i < 32; i++ {
-		if hash[31-i] > target[31-i] {
-			return false
-		}
-		if hash[31-i] < target[31-i] {
-			return true
-		}
+// DefaultBackend is the backend the miner uses unless told otherwise
+const DefaultBackend = "lean-cgo"
+
+func cgoBackendName(algo Algorithm) string {
+	return algo.String() + "-cgo"
+}
+
+func init() {
+	for _, algo := range []Algorithm{Lean, Mean} {
+		algo := algo
+		RegisterBackend(BackendInfo{
+			Name:          cgoBackendName(algo),
+			Algorithm:     algo,
+			MultiThreaded: algo == Mean,
+			Native:        true,
+			Sizes:         func() []Params { return SupportedParams(algo) },
+			New: func(params Params, threads int) (Backend, error) {
+				s, err := NewSolver(Options{Algorithm: algo, Params: params, Threads: threads,
+					HugePages: HugePages(defaultHugePages.Load())})
+				if err != nil {
+					return nil, err
+				}
+				return s, nil
+			},
+			Memory: func(params Params, threads int) (uint64, error) {
+				return MemoryRequired(Options{Algorithm: algo, Params: params, Threads: threads})
+			},
+		})
 	}
-	return true
 }
 
-// GetStats returns solver statistics
-func (s *Solver) GetStats() string {
-	return fmt.Sprintf("Solver: %d threads, EdgeBits: %d, ProofSize: %d",
-		s.nthreads, EdgeBits, ProofSize)
+// liveContexts reports how many C contexts are currently allocated
+func liveContexts() int {
+	return int(C.cuckoo_live_contexts())
 }
 
-// Close releases C-side resources for the solver context.
-func (s *Solver) Close() {}
+// implCounts reports how many C solver states have been created and
+// destroyed
+func implCounts() (created, destroyed uint64) {
+	var c, d C.uint64_t
+	C.cuckoo_impl_counts(&c, &d)
+	return uint64(c), uint64(d)
+}
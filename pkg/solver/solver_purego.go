@@ -0,0 +1,90 @@
+//go:build !cgo || purego
+
+package solver
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// DefaultBackend is the backend the miner uses unless told otherwise
+const DefaultBackend = "purego"
+
+// Solver is the pure-Go solver in builds without cgo
+type Solver = GoSolver
+
+// NewSolver creates a new Cuckoo solver running opts.Algorithm on the given
+// graph parameters with opts.Threads threads per graph. Only Lean is
+// available in this build.
+func NewSolver(opts Options) (*Solver, error) {
+	opts = opts.withDefaults()
+	if err := checkOptions(opts); err != nil {
+		return nil, err
+	}
+	return NewGoSolver(opts.Params, opts.Threads)
+}
+
+// SupportedParams lists the graph parameters available for an algorithm
+func SupportedParams(algo Algorithm) []Params {
+	if algo != Lean {
+		return nil
+	}
+	return GoSolverParams()
+}
+
+func supported(algo Algorithm, p Params) bool {
+	for _, sp := range SupportedParams(algo) {
+		if sp == p {
+			return true
+		}
+	}
+	return false
+}
+
+// checkOptions rejects options no built solver can serve
+func checkOptions(opts Options) error {
+	if opts.Threads < 1 {
+		return fmt.Errorf("solver: invalid thread count %d", opts.Threads)
+	}
+	if err := opts.Params.Validate(); err != nil {
+		return err
+	}
+	if !supported(opts.Algorithm, opts.Params) {
+		return fmt.Errorf("solver: no %s solver built for %s (have %v)",
+			opts.Algorithm, opts.Params, SupportedParams(opts.Algorithm))
+	}
+	return nil
+}
+
+// MemoryRequired reports how many bytes of solver memory NewSolver(opts)
+// will allocate, without allocating it
+func MemoryRequired(opts Options) (uint64, error) {
+	opts = opts.withDefaults()
+	if err := checkOptions(opts); err != nil {
+		return 0, err
+	}
+	return goLeanMembytes(opts.Params), nil
+}
+
+// Verify checks if a solution is valid for a graph with the given parameters.
+// header is the header passed to SetHeader; the graph does not depend on
+// nonce.
+func Verify(params Params, header []byte, nonce uint32, proof []uint32) bool {
+	return goVerify(params, header, proof)
+}
+
+// liveContexts reports how many solvers are currently open
+func liveContexts() int {
+	return int(liveSolvers.Load())
+}
+
+// implCounts reports how many times solver memory has been allocated and
+// released
+func implCounts() (created, destroyed uint64) {
+	return goLeanAllocs.Load(), goLeanReleases.Load()
+}
+
+// setNativeLogger is a no-op: the pure-Go solver logs through solverLogger
+// directly
+func setNativeLogger(*zap.Logger) {}
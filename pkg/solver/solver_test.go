@@ -1,8 +1,20 @@
 package solver
 
 import (
+	"context"
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"runtime"
+	"sync"
 	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 func TestSolver(t *testing.T) {
@@ -17,18 +29,27 @@ func TestSolver(t *testing.T) {
 	}
 
 	// Create solver
-	s := NewSolver(1)
-	s.SetHeader(header)
+	s, err := NewSolver(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.SetHeader(header); err != nil {
+		t.Fatal(err)
+	}
 
 	// Try to find solution
-	solutions := s.Solve(0, 1000)
+	solutions, err := s.Solve(0, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	t.Logf("Found %d solutions", len(solutions))
 	for i, sol := range solutions {
 		t.Logf("Solution %d: %v", i, sol.Nonce)
 
 		// Verify solution
-		if !Verify(header, 0, sol.Nonce) {
+		if !Verify(DefaultParams, header, 0, sol.Nonce) {
 			t.Errorf("Solution %d failed verification", i)
 		}
 	}
@@ -41,7 +62,11 @@ func BenchmarkSolver(b *testing.B) {
 
 	header, _ := hex.DecodeString(headerHex)
 
-	s := NewSolver(1)
+	s, err := NewSolver(Options{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer s.Close()
 	s.SetHeader(header)
 
 	b.ResetTimer()
@@ -50,6 +75,317 @@ func BenchmarkSolver(b *testing.B) {
 	}
 }
 
+func TestSolverLifecycleNoLeak(t *testing.T) {
+	iterations := 5000
+	if DefaultBackend == "purego" {
+		// A pure-Go graph takes ~100ms even at 19 edge bits
+		iterations = 50
+	}
+	cycle := func() {
+		s, err := NewSolver(Options{Params: Params{EdgeBits: 19, ProofSize: 42}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Solver memory is only allocated by the first Solve
+		if err := s.SetHeader(make([]byte, 80)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Solve(0, 1); err != nil {
+			t.Fatal(err)
+		}
+		s.Close()
+		s.Close() // idempotent
+	}
+	// Warm up so the allocators' own footprint is in the baseline
+	for i := 0; i < 10; i++ {
+		cycle()
+	}
+	runtime.GC()
+	baseRSS, haveRSS := residentBytes()
+	created0, destroyed0 := implCounts()
+
+	for i := 0; i < iterations; i++ {
+		cycle()
+	}
+
+	created, destroyed := implCounts()
+	if created-created0 != uint64(iterations) {
+		t.Errorf("created %d solver states in %d solves, want one each", created-created0, iterations)
+	}
+	if destroyed-destroyed0 != created-created0 {
+		t.Errorf("created %d solver states but destroyed %d", created-created0, destroyed-destroyed0)
+	}
+	// Every solver state leaked would hold ~200 KiB at 19 edge bits
+	runtime.GC()
+	if rss, ok := residentBytes(); ok && haveRSS && rss > baseRSS+64<<20 {
+		t.Errorf("RSS grew from %d to %d bytes over %d solvers", baseRSS, rss, iterations)
+	}
+}
+
+// residentBytes reads the process's resident set size from /proc
+func residentBytes() (uint64, bool) {
+	b, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return 0, false
+	}
+	var size, resident uint64
+	if _, err := fmt.Sscan(string(b), &size, &resident); err != nil {
+		return 0, false
+	}
+	return resident * uint64(os.Getpagesize()), true
+}
+
+func TestSolveAfterClose(t *testing.T) {
+	s, err := NewSolver(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	if _, err := s.Solve(0, 1); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Solve after Close: got %v, want ErrClosed", err)
+	}
+	if err := s.SetHeader(make([]byte, 80)); !errors.Is(err, ErrClosed) {
+		t.Fatalf("SetHeader after Close: got %v, want ErrClosed", err)
+	}
+	s.Cancel() // must not touch freed memory
+}
+
+func TestConcurrentSolveRejected(t *testing.T) {
+	s, err := NewSolver(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.SetHeader(make([]byte, 80)); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.SolveContext(ctx, 0, 1<<20)
+	}()
+	for !s.busy.Load() {
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := s.Solve(0, 1); !errors.Is(err, ErrBusy) {
+		t.Errorf("concurrent Solve: got %v, want ErrBusy", err)
+	}
+	cancel()
+	<-done
+}
+
+func TestSolveContextCancel(t *testing.T) {
+	s, err := NewSolver(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.SetHeader(make([]byte, 80))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.SolveContext(ctx, 0, 1<<30); !errors.Is(err, context.Canceled) {
+		t.Fatalf("pre-cancelled context: got %v, want context.Canceled", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := s.SolveContext(ctx, 0, 1<<30); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("cancellation took %v", elapsed)
+	}
+}
+
+func TestLastGraphs(t *testing.T) {
+	s, err := NewSolver(Options{Params: Params{EdgeBits: 19, ProofSize: 42}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.SetHeader(make([]byte, 80))
+
+	if _, err := s.Solve(0, 3); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.LastGraphs(); n < 1 || n > 3 {
+		t.Fatalf("LastGraphs after a 3-nonce Solve = %d", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.SolveContext(ctx, 0, 3)
+	if n := s.LastGraphs(); n != 0 {
+		t.Fatalf("LastGraphs after a cancelled Solve = %d, want 0", n)
+	}
+}
+
+func TestLastGraphsPartial(t *testing.T) {
+	s, err := NewSolver(Options{Params: Params{EdgeBits: 19, ProofSize: 42}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.SetHeader(make([]byte, 80))
+
+	// Cut a long range short: the graphs finished before the abort count
+	const nonces = 1 << 20
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	s.SolveContext(ctx, 0, nonces)
+	if n := s.LastGraphs(); n < 0 || n >= nonces {
+		t.Fatalf("LastGraphs after an aborted %d-nonce Solve = %d", nonces, n)
+	}
+}
+
+// TestCancelStress hammers Cancel from several goroutines while solves start
+// and stop, then closes the solver under fire. Run with -race (and -asan
+// against `make asan`) to catch lifetime bugs on the C side.
+func TestCancelStress(t *testing.T) {
+	for round := 0; round < 20; round++ {
+		s, err := NewSolver(Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.SetHeader(make([]byte, 80))
+
+		stop := make(chan struct{})
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+						s.Cancel()
+						time.Sleep(time.Duration(rand.Intn(200)) * time.Microsecond)
+					}
+				}
+			}()
+		}
+
+		for i := 0; i < 10; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Duration(rand.Intn(5))*time.Millisecond)
+			s.SolveContext(ctx, uint32(i), 1<<20)
+			cancel()
+		}
+
+		s.Close()
+		close(stop)
+		wg.Wait()
+	}
+}
+
+func TestSolverLogHook(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	SetLogger(zap.New(core))
+	defer SetLogger(nil)
+
+	s, err := NewSolver(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.SetHeader(make([]byte, 80))
+	if _, err := s.Solve(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if logs.FilterLevelExact(zapcore.DebugLevel).Len() == 0 {
+		t.Fatal("expected solver debug diagnostics with a Debug logger")
+	}
+
+	// Info-level logger keeps the solve loop silent
+	core, logs = observer.New(zapcore.InfoLevel)
+	SetLogger(zap.New(core))
+	if _, err := s.Solve(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if logs.Len() != 0 {
+		t.Fatalf("unexpected diagnostics at Info level: %v", logs.All())
+	}
+
+	// Raising the level of a running logger takes effect once synced
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	core, logs = observer.New(level)
+	SetLogger(zap.New(core))
+	level.SetLevel(zapcore.DebugLevel)
+	SyncLogLevel()
+	if _, err := s.Solve(0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if logs.FilterLevelExact(zapcore.DebugLevel).Len() == 0 {
+		t.Fatal("expected solver debug diagnostics after raising the level")
+	}
+}
+
+func TestSolverParams(t *testing.T) {
+	small := Params{EdgeBits: 19, ProofSize: 42}
+	s, err := NewSolver(Options{Params: small})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if s.Params() != small {
+		t.Fatalf("Params() = %v, want %v", s.Params(), small)
+	}
+	s.SetHeader(make([]byte, 80))
+	if _, err := s.Solve(0, 4); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewSolver(Options{Params: Params{EdgeBits: 17, ProofSize: 42}}); err == nil {
+		t.Error("expected error for graph size without a built solver")
+	}
+	if _, err := NewSolver(Options{Params: Params{EdgeBits: 23, ProofSize: 41}}); err == nil {
+		t.Error("expected error for odd proof size")
+	}
+}
+
+func TestMeanSolver(t *testing.T) {
+	if len(SupportedParams(Mean)) == 0 {
+		t.Skip("mean solver not built")
+	}
+	opts := Options{Algorithm: Mean, Params: Params{EdgeBits: 19, ProofSize: 42}, Threads: 2}
+
+	mem, err := MemoryRequired(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lean, err := MemoryRequired(Options{Params: opts.Params})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mem == 0 || lean == 0 {
+		t.Fatalf("memory estimates must be non-zero: mean=%d lean=%d", mem, lean)
+	}
+
+	s, err := NewSolver(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if s.Algorithm() != Mean {
+		t.Fatalf("Algorithm() = %v, want mean", s.Algorithm())
+	}
+	s.SetHeader(make([]byte, 80))
+	if _, err := s.Solve(0, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewSolver(Options{Algorithm: Mean, Params: Params{EdgeBits: 31, ProofSize: 42}}); err == nil {
+		t.Error("expected error: mean is not built for 31 edge bits")
+	}
+}
+
 func TestDifficultyCheck(t *testing.T) {
 	// Test hash (all zeros should pass any target)
 	hash := [32]byte{}
@@ -79,3 +415,48 @@ func TestDifficultyCheck(t *testing.T) {
 		t.Error("Hash 0x03 should not pass target 0x02")
 	}
 }
+
+func TestHugePages(t *testing.T) {
+	for _, mode := range []HugePages{HugePagesOff, HugePagesTHP, HugePagesHugeTLB} {
+		if got, err := ParseHugePages(mode.String()); err != nil || got != mode {
+			t.Fatalf("ParseHugePages(%q) = %v, %v", mode, got, err)
+		}
+
+		s, err := NewSolver(Options{Params: Params{EdgeBits: 23, ProofSize: 42}, HugePages: mode})
+		if err != nil {
+			t.Fatal(err)
+		}
+		hr, ok := any(s).(HugePageReporter)
+		if !ok {
+			s.Close()
+			t.Skip("solver does not manage huge pages")
+		}
+		s.SetHeader(make([]byte, 80))
+		if _, err := s.Solve(0, 1); err != nil {
+			t.Fatal(err)
+		}
+		st := hr.HugePages()
+		t.Logf("%s: %+v", mode, st)
+		if st.Mode != mode {
+			t.Errorf("Mode = %v, want %v", st.Mode, mode)
+		}
+		if mode == HugePagesOff && st.Bytes != 0 {
+			t.Errorf("off: %d bytes attributed to huge page allocation", st.Bytes)
+		}
+		// The nonleaf counters of a 2^23-edge graph fill one huge page, and
+		// Linux maps them whether or not huge pages take
+		if mode != HugePagesOff && runtime.GOOS == "linux" && st.Bytes == 0 {
+			t.Errorf("%s: no buffers in huge page backed memory", mode)
+		}
+		if st.Huge() > st.Bytes {
+			t.Errorf("%s: %d huge bytes out of %d", mode, st.Huge(), st.Bytes)
+		}
+		s.Close()
+		if st := hr.HugePages(); st.Bytes != 0 {
+			t.Errorf("%s: %d bytes still mapped after Close", mode, st.Bytes)
+		}
+	}
+	if _, err := ParseHugePages("always"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
@@ -0,0 +1,32 @@
+package stats
+
+import (
+	"maps"
+	"sync"
+)
+
+// Counters is a set of event counts keyed by a label, such as share
+// rejections by reason. It is safe for concurrent use.
+type Counters struct {
+	mu     sync.Mutex
+	counts map[string]uint64
+}
+
+// NewCounters returns an empty set of counters
+func NewCounters() *Counters {
+	return &Counters{counts: make(map[string]uint64)}
+}
+
+// Add records n events under label
+func (c *Counters) Add(label string, n uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[label] += n
+}
+
+// Snapshot returns a copy of the counts by label
+func (c *Counters) Snapshot() map[string]uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return maps.Clone(c.counts)
+}
@@ -0,0 +1,91 @@
+package stats
+
+import (
+	"math"
+	"sync"
+)
+
+// LatencyBuckets are upper bounds in seconds suited to job-switch and
+// solve latencies, from a millisecond to ten seconds
+var LatencyBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Histogram counts observations into buckets by upper bound, like a
+// Prometheus histogram. Observations above the last bound land in an
+// implicit +Inf bucket. It is safe for concurrent use.
+type Histogram struct {
+	bounds []float64
+
+	mu     sync.Mutex
+	counts []uint64 // per bucket, not cumulative; the last is +Inf
+	sum    float64
+	max    float64
+}
+
+// HistogramSnapshot is a copy of a Histogram's state
+type HistogramSnapshot struct {
+	Bounds []float64
+	Counts []uint64 // cumulative: Counts[i] observations were <= Bounds[i]
+	Count  uint64
+	Sum    float64
+	Max    float64
+}
+
+// NewHistogram returns a histogram with the given ascending upper bounds
+func NewHistogram(bounds []float64) *Histogram {
+	return &Histogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+// Observe records v
+func (h *Histogram) Observe(v float64) {
+	i := 0
+	for i < len(h.bounds) && v > h.bounds[i] {
+		i++
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.counts[i]++
+	h.sum += v
+	h.max = max(h.max, v)
+}
+
+// Snapshot returns the observations recorded so far
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := HistogramSnapshot{
+		Bounds: h.bounds,
+		Counts: make([]uint64, len(h.bounds)),
+		Sum:    h.sum,
+		Max:    h.max,
+	}
+	for i, c := range h.counts {
+		s.Count += c
+		if i < len(s.Counts) {
+			s.Counts[i] = s.Count
+		}
+	}
+	return s
+}
+
+// Quantile estimates the q-quantile (0 < q <= 1) by interpolating within
+// its bucket, as Prometheus' histogram_quantile does. Quantiles in the +Inf
+// bucket are reported as the largest observation; with no observations
+// it returns NaN.
+func (s HistogramSnapshot) Quantile(q float64) float64 {
+	if s.Count == 0 {
+		return math.NaN()
+	}
+	rank := q * float64(s.Count)
+	lower, below := 0.0, uint64(0)
+	for i, c := range s.Counts {
+		if float64(c) >= rank && c > below {
+			frac := (rank - float64(below)) / float64(c-below)
+			return min(lower+frac*(s.Bounds[i]-lower), s.Max)
+		}
+		lower, below = s.Bounds[i], c
+	}
+	return s.Max
+}
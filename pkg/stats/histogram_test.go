@@ -0,0 +1,39 @@
+package stats
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram([]float64{1, 2, 4})
+	if q := h.Snapshot().Quantile(0.5); !math.IsNaN(q) {
+		t.Fatalf("quantile of an empty histogram = %v, want NaN", q)
+	}
+	for _, v := range []float64{0.5, 1, 1.5, 3, 3, 8} {
+		h.Observe(v)
+	}
+	s := h.Snapshot()
+	if s.Count != 6 || s.Sum != 17 || s.Max != 8 {
+		t.Fatalf("count/sum/max = %d/%v/%v, want 6/17/8", s.Count, s.Sum, s.Max)
+	}
+	// Bounds are inclusive and counts cumulative
+	for i, want := range []uint64{2, 3, 5} {
+		if s.Counts[i] != want {
+			t.Fatalf("Counts = %v, want [2 3 5]", s.Counts)
+		}
+	}
+
+	// The median (rank 3) is the top of the (1,2] bucket
+	if q := s.Quantile(0.5); q != 2 {
+		t.Fatalf("p50 = %v, want 2", q)
+	}
+	// Rank 4 is halfway through the (2,4] bucket
+	if q := s.Quantile(4.0 / 6); math.Abs(q-3) > 1e-9 {
+		t.Fatalf("p67 = %v, want 3", q)
+	}
+	// Beyond the last bound only the maximum is known
+	if q := s.Quantile(1); q != 8 {
+		t.Fatalf("p100 = %v, want 8", q)
+	}
+}
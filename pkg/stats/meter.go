@@ -0,0 +1,84 @@
+// Package stats keeps miner counters and their smoothed rates
+package stats
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Windows are the EMA windows rates are smoothed over, like load averages
+var Windows = [3]time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute}
+
+// Meter counts events and tracks their rate per second as exponential
+// moving averages over Windows. Add may be called from any goroutine; Tick
+// is called periodically by one owner.
+type Meter struct {
+	total atomic.Uint64
+
+	mu        sync.Mutex
+	lastTotal uint64
+	lastTick  time.Time
+	rates     [len(Windows)]float64
+	last      float64 // rate over the last interval
+	seeded    bool
+}
+
+// NewMeter returns a Meter whose first interval starts at now
+func NewMeter(now time.Time) *Meter {
+	return &Meter{lastTick: now}
+}
+
+// Add records n events
+func (m *Meter) Add(n uint64) {
+	m.total.Add(n)
+}
+
+// Total returns the number of events recorded so far
+func (m *Meter) Total() uint64 {
+	return m.total.Load()
+}
+
+// Tick folds the events since the previous Tick into the averages and
+// returns their rate over that interval. The first Tick seeds every average
+// with that rate so a fresh miner doesn't report a slow ramp-up.
+func (m *Meter) Tick(now time.Time) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dt := now.Sub(m.lastTick)
+	if dt <= 0 {
+		return 0
+	}
+	total := m.total.Load()
+	instant := float64(total-m.lastTotal) / dt.Seconds()
+	m.lastTotal = total
+	m.lastTick = now
+	m.last = instant
+
+	for i, w := range Windows {
+		if !m.seeded {
+			m.rates[i] = instant
+			continue
+		}
+		alpha := 1 - math.Exp(-dt.Seconds()/w.Seconds())
+		m.rates[i] += alpha * (instant - m.rates[i])
+	}
+	m.seeded = true
+	return instant
+}
+
+// Rates returns the averaged rates per second, one per entry of Windows
+func (m *Meter) Rates() [len(Windows)]float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.rates
+}
+
+// LastRate returns the rate per second over the interval before the last Tick
+func (m *Meter) LastRate() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.last
+}
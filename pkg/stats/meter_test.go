@@ -0,0 +1,42 @@
+package stats
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMeterEMA(t *testing.T) {
+	start := time.Unix(0, 0)
+	m := NewMeter(start)
+	now := start
+
+	// Seeded by the first tick
+	m.Add(100)
+	now = now.Add(10 * time.Second)
+	if got := m.Tick(now); got != 10 || m.LastRate() != 10 {
+		t.Fatalf("instant rate = %v (LastRate %v), want 10", got, m.LastRate())
+	}
+	for i, r := range m.Rates() {
+		if r != 10 {
+			t.Fatalf("rate[%d] = %v after seeding, want 10", i, r)
+		}
+	}
+
+	// Then the rate drops to zero: the 1m average must fall fastest
+	for i := 0; i < 6; i++ {
+		now = now.Add(10 * time.Second)
+		m.Tick(now)
+	}
+	r := m.Rates()
+	if !(r[0] < r[1] && r[1] < r[2]) {
+		t.Fatalf("expected 1m < 5m < 15m after a drop, got %v", r)
+	}
+	// After one full window the 1m average keeps e^-1 of the old rate
+	if want := 10 * math.Exp(-1); math.Abs(r[0]-want) > 1e-9 {
+		t.Fatalf("1m rate = %v, want %v", r[0], want)
+	}
+	if m.Total() != 100 {
+		t.Fatalf("Total = %d, want 100", m.Total())
+	}
+}
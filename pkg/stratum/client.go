@@ -4,13 +4,16 @@ package stratum
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"math/bits"
 	"net"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/nitrogen/go-miner/pkg/stats"
 	"go.uber.org/zap"
 )
 
@@ -21,6 +24,9 @@ type Client struct {
 	conn   net.Conn
 	reader *bufio.Reader
 	writer *bufio.Writer
+	// writeMu keeps concurrent calls, such as submits from several
+	// workers, from interleaving their lines
+	writeMu sync.Mutex
 
 	// Authentication
 	username string
@@ -28,6 +34,7 @@ type Client struct {
 
 	// State
 	connected       atomic.Bool
+	closed          atomic.Bool // by Close; no more reconnects
 	subscribed      atomic.Bool
 	authorized      atomic.Bool
 	extraNonce1     string
@@ -43,8 +50,10 @@ type Client struct {
 	pendingMutex sync.RWMutex
 
 	// Callbacks
-	onNewWork   func(*Work)
-	onReconnect func()
+	onNewWork    func(*Work)
+	onReconnect  func()
+	onDifficulty func(float64)
+	onDisconnect func(error)
 
 	// Logging
 	logger *zap.Logger
@@ -53,8 +62,19 @@ type Client struct {
 	stopCh chan struct{}
 	workCh chan *Work
 
-	// Difficulty
-	difficulty float64
+	// Difficulty, as math.Float64bits; set by the reader, read by miners
+	difficulty atomic.Uint64
+
+	// Cuckoo graph parameters used when a job doesn't carry its own
+	edgeBits  int
+	proofSize int
+
+	// Statistics
+	reconnects atomic.Uint64
+	since      atomic.Int64 // unix nanoseconds the current connection was authorized
+	lastJob    atomic.Int64 // unix nanoseconds of the last mining.notify
+	rpcMu      sync.Mutex
+	rpcLatency map[string]*stats.Histogram // by method
 }
 
 // Work represents mining job from pool
@@ -72,6 +92,10 @@ type Work struct {
 	ExtraNonce2Size int
 	Target          []byte
 	difficulty      float64
+
+	// Cuckoo graph parameters for this job
+	EdgeBits  int
+	ProofSize int
 }
 
 // Request represents JSON-RPC request
@@ -100,6 +124,19 @@ type Error struct {
 	Message string `json:"message"`
 }
 
+func (e *Error) Error() string {
+	return "RPC error: " + e.Message
+}
+
+// RejectedError is returned by SubmitWork when the pool refuses a share
+type RejectedError struct {
+	Reason string // the pool's error message, or "rejected"
+}
+
+func (e *RejectedError) Error() string {
+	return "submission rejected: " + e.Reason
+}
+
 // NewClient creates a new Stratum client
 func NewClient(addr, username, password string, logger *zap.Logger) *Client {
 	return &Client{
@@ -110,6 +147,8 @@ func NewClient(addr, username, password string, logger *zap.Logger) *Client {
 		pending:  make(map[int64]chan *Response),
 		stopCh:   make(chan struct{}),
 		workCh:   make(chan *Work, 1),
+
+		rpcLatency: make(map[string]*stats.Histogram),
 	}
 }
 
@@ -118,11 +157,29 @@ func (c *Client) SetWorkHandler(handler func(*Work)) {
 	c.onNewWork = handler
 }
 
+// SetCuckooParams sets the graph parameters assigned to jobs that don't
+// specify their own
+func (c *Client) SetCuckooParams(edgeBits, proofSize int) {
+	c.edgeBits = edgeBits
+	c.proofSize = proofSize
+}
+
 // SetReconnectHandler sets callback for reconnection
 func (c *Client) SetReconnectHandler(handler func()) {
 	c.onReconnect = handler
 }
 
+// SetDifficultyHandler sets callback for mining.set_difficulty
+func (c *Client) SetDifficultyHandler(handler func(float64)) {
+	c.onDifficulty = handler
+}
+
+// SetDisconnectHandler sets callback for connection loss, with the read
+// error; the client then reconnects by itself
+func (c *Client) SetDisconnectHandler(handler func(error)) {
+	c.onDisconnect = handler
+}
+
 // Connect establishes connection to pool
 func (c *Client) Connect() error {
 	c.logger.Info("Connecting to pool", zap.String("addr", c.addr))
@@ -142,23 +199,32 @@ func (c *Client) Connect() error {
 
 	// Subscribe and authorize
 	if err := c.subscribe(); err != nil {
-		c.Close()
+		c.closeConn()
 		return err
 	}
 
 	if err := c.authorize(); err != nil {
-		c.Close()
+		c.closeConn()
 		return err
 	}
 
+	c.since.Store(time.Now().UnixNano())
 	c.logger.Info("Connected and authorized")
 	return nil
 }
 
-// Close closes the connection
+// Close closes the connection for good: the client won't reconnect
 func (c *Client) Close() {
-	c.connected.Store(false)
+	if c.closed.Swap(true) {
+		return
+	}
 	close(c.stopCh)
+	c.closeConn()
+}
+
+// closeConn drops the connection; unlike Close, the client may connect again
+func (c *Client) closeConn() {
+	c.connected.Store(false)
 	if c.conn != nil {
 		c.conn.Close()
 	}
@@ -246,6 +312,10 @@ func (c *Client) SubmitWork(work *Work, nonce2 string, nTime string, nonce uint3
 	}
 
 	resp, err := c.call(req)
+	var rpcErr *Error
+	if errors.As(err, &rpcErr) {
+		return &RejectedError{Reason: rpcErr.Message}
+	}
 	if err != nil {
 		return err
 	}
@@ -256,7 +326,7 @@ func (c *Client) SubmitWork(work *Work, nonce2 string, nTime string, nonce uint3
 	}
 
 	if !result {
-		return fmt.Errorf("submission rejected")
+		return &RejectedError{Reason: "rejected"}
 	}
 
 	c.logger.Info("Share accepted", zap.String("jobID", work.JobID))
@@ -275,8 +345,12 @@ func (c *Client) readLoop() {
 	for c.connected.Load() {
 		line, err := c.reader.ReadString('\n')
 		if err != nil {
+			// Close or a failed Connect dropped the connection on purpose
+			if !c.connected.CompareAndSwap(true, false) {
+				return
+			}
 			c.logger.Error("Read error", zap.Error(err))
-			c.handleDisconnect()
+			c.handleDisconnect(err)
 			return
 		}
 
@@ -357,6 +431,19 @@ func (c *Client) handleMiningNotify(params []interface{}) {
 		CleanJobs:       params[8].(bool),
 		ExtraNonce1:     c.extraNonce1,
 		ExtraNonce2Size: c.extraNonce2Size,
+		EdgeBits:        c.edgeBits,
+		ProofSize:       c.proofSize,
+	}
+
+	// Pools mining non-default graph sizes append edge bits and proof size
+	// after clean_jobs
+	if len(params) >= 11 {
+		if eb, ok := params[9].(float64); ok {
+			work.EdgeBits = int(eb)
+		}
+		if ps, ok := params[10].(float64); ok {
+			work.ProofSize = int(ps)
+		}
 	}
 
 	// Parse merkle branch
@@ -371,6 +458,7 @@ func (c *Client) handleMiningNotify(params []interface{}) {
 	c.workMutex.Lock()
 	c.currentWork = work
 	c.workMutex.Unlock()
+	c.lastJob.Store(time.Now().UnixNano())
 
 	if c.onNewWork != nil {
 		c.onNewWork(work)
@@ -383,9 +471,12 @@ func (c *Client) handleMiningNotify(params []interface{}) {
 func (c *Client) handleSetDifficulty(params []interface{}) {
 	if len(params) > 0 {
 		if diff, ok := params[0].(float64); ok {
-			c.difficulty = diff
+			c.difficulty.Store(math.Float64bits(diff))
 			c.logger.Info("Difficulty set", zap.Float64("difficulty", diff))
 			// target calculation is handled by miner using this value
+			if c.onDifficulty != nil {
+				c.onDifficulty(diff)
+			}
 		}
 	}
 }
@@ -397,15 +488,18 @@ func (c *Client) handleReconnectRequest(params []interface{}) {
 }
 
 // handleDisconnect handles connection loss
-func (c *Client) handleDisconnect() {
+func (c *Client) handleDisconnect(err error) {
 	c.connected.Store(false)
 	c.logger.Warn("Disconnected from pool")
+	if c.onDisconnect != nil {
+		c.onDisconnect(err)
+	}
 	go c.reconnect()
 }
 
 // reconnect attempts to reconnect
 func (c *Client) reconnect() {
-	for !c.connected.Load() {
+	for !c.connected.Load() && !c.closed.Load() {
 		c.logger.Info("Attempting reconnect...")
 		if err := c.Connect(); err != nil {
 			c.logger.Error("Reconnect failed", zap.Error(err))
@@ -413,6 +507,7 @@ func (c *Client) reconnect() {
 			continue
 		}
 
+		c.reconnects.Add(1)
 		if c.onReconnect != nil {
 			c.onReconnect()
 		}
@@ -422,7 +517,69 @@ func (c *Client) reconnect() {
 
 // GetDifficulty returns the last set pool difficulty
 func (c *Client) GetDifficulty() float64 {
-	return c.difficulty
+	return math.Float64frombits(c.difficulty.Load())
+}
+
+// Addr returns the pool address
+func (c *Client) Addr() string {
+	return c.addr
+}
+
+// Username returns the worker name the client authorizes as
+func (c *Client) Username() string {
+	return c.username
+}
+
+// Connected reports whether the connection to the pool is up
+func (c *Client) Connected() bool {
+	return c.connected.Load()
+}
+
+// Reconnects returns how many times the client reconnected to the pool
+func (c *Client) Reconnects() uint64 {
+	return c.reconnects.Load()
+}
+
+// ConnectedSince returns when the current connection was authorized, or
+// the zero time if the client never connected
+func (c *Client) ConnectedSince() time.Time {
+	ns := c.since.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// LastJobTime returns when the last job arrived, or the zero time
+func (c *Client) LastJobTime() time.Time {
+	ns := c.lastJob.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// RPCLatency returns the round-trip times of answered calls by method
+func (c *Client) RPCLatency() map[string]stats.HistogramSnapshot {
+	c.rpcMu.Lock()
+	defer c.rpcMu.Unlock()
+	out := make(map[string]stats.HistogramSnapshot, len(c.rpcLatency))
+	for method, h := range c.rpcLatency {
+		out[method] = h.Snapshot()
+	}
+	return out
+}
+
+// observeRPC records the round-trip time of an answered call
+func (c *Client) observeRPC(method string, d time.Duration) {
+	c.rpcMu.Lock()
+	h, ok := c.rpcLatency[method]
+	if !ok {
+		h = stats.NewHistogram(stats.LatencyBuckets)
+		c.rpcLatency[method] = h
+	}
+	c.rpcMu.Unlock()
+	h.Observe(d.Seconds())
 }
 
 // call makes RPC call
@@ -437,18 +594,22 @@ func (c *Client) call(req *Request) (*Response, error) {
 	c.pending[req.ID] = ch
 	c.pendingMutex.Unlock()
 
-	if _, err := c.writer.Write(append(data, '\n')); err != nil {
-		return nil, err
+	start := time.Now()
+	c.writeMu.Lock()
+	_, err = c.writer.Write(append(data, '\n'))
+	if err == nil {
+		err = c.writer.Flush()
 	}
-
-	if err := c.writer.Flush(); err != nil {
+	c.writeMu.Unlock()
+	if err != nil {
 		return nil, err
 	}
 
 	select {
 	case resp := <-ch:
+		c.observeRPC(req.Method, time.Since(start))
 		if resp.Error != nil {
-			return nil, fmt.Errorf("RPC error: %s", resp.Error.Message)
+			return nil, resp.Error
 		}
 		return resp, nil
 	case <-time.After(30 * time.Second):
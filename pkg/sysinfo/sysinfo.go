@@ -0,0 +1,149 @@
+// Package sysinfo probes the host for the cache, memory and CPU layout the
+// miner sizes its solvers by. Values that cannot be determined are zero.
+package sysinfo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Cache is one shared cache instance and the CPUs that share it
+type Cache struct {
+	Level int
+	Size  uint64 // bytes
+	CPUs  []int
+}
+
+// Node is a NUMA node and its CPUs
+type Node struct {
+	ID   int
+	CPUs []int
+}
+
+// Memory is the system memory as reported by the OS
+type Memory struct {
+	Total     uint64 // bytes
+	Available uint64 // bytes that can be allocated without swapping
+}
+
+// CPUTimes are cumulative CPU times of all CPUs in clock ticks (USER_HZ)
+type CPUTimes struct {
+	Busy  uint64 // time not idle or waiting for I/O
+	Total uint64
+}
+
+// L3Bytes returns the total size of all last-level (L3) caches
+func L3Bytes() uint64 {
+	var total uint64
+	for _, c := range Caches(3) {
+		total += c.Size
+	}
+	return total
+}
+
+// NodeOf returns the node holding all of cpus, or -1 if they span nodes or
+// the layout is unknown
+func NodeOf(nodes []Node, cpus []int) int {
+	for _, n := range nodes {
+		in := make(map[int]bool, len(n.CPUs))
+		for _, c := range n.CPUs {
+			in[c] = true
+		}
+		all := len(cpus) > 0
+		for _, c := range cpus {
+			all = all && in[c]
+		}
+		if all {
+			return n.ID
+		}
+	}
+	return -1
+}
+
+// ParseCPUList parses a kernel CPU list such as "0-3,8,10-11"
+func ParseCPUList(s string) ([]int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	var cpus []int
+	for _, part := range strings.Split(s, ",") {
+		lo, hi, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, fmt.Errorf("sysinfo: bad cpu list %q", s)
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(hi); err != nil || last < first {
+				return nil, fmt.Errorf("sysinfo: bad cpu list %q", s)
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
+
+// parseCPUTimes parses the aggregate "cpu" line of /proc/stat
+func parseCPUTimes(line string) (CPUTimes, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return CPUTimes{}, fmt.Errorf("sysinfo: bad cpu line %q", line)
+	}
+	// user nice system idle iowait irq softirq steal; guest time is
+	// already counted in user
+	var t CPUTimes
+	for i, f := range fields[1:min(len(fields), 9)] {
+		n, err := strconv.ParseUint(f, 10, 64)
+		if err != nil {
+			return CPUTimes{}, fmt.Errorf("sysinfo: bad cpu line %q", line)
+		}
+		t.Total += n
+		if i != 3 && i != 4 {
+			t.Busy += n
+		}
+	}
+	return t, nil
+}
+
+// parseProcessCPU returns utime+stime in clock ticks from /proc/<pid>/stat
+func parseProcessCPU(stat string) (uint64, error) {
+	// The command name may contain spaces and parentheses; it ends at the
+	// last ')', after which come the state (field 3) and the rest
+	i := strings.LastIndexByte(stat, ')')
+	if i < 0 {
+		return 0, fmt.Errorf("sysinfo: bad process stat")
+	}
+	fields := strings.Fields(stat[i+1:])
+	if len(fields) < 13 {
+		return 0, fmt.Errorf("sysinfo: bad process stat")
+	}
+	utime, err1 := strconv.ParseUint(fields[11], 10, 64)
+	stime, err2 := strconv.ParseUint(fields[12], 10, 64)
+	if err1 != nil || err2 != nil {
+		return 0, fmt.Errorf("sysinfo: bad process stat")
+	}
+	return utime + stime, nil
+}
+
+// parseSize parses sysfs cache sizes such as "32768K" or "2M"
+func parseSize(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	mult := uint64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		mult, s = 1<<10, strings.TrimSuffix(s, "K")
+	case strings.HasSuffix(s, "M"):
+		mult, s = 1<<20, strings.TrimSuffix(s, "M")
+	case strings.HasSuffix(s, "G"):
+		mult, s = 1<<30, strings.TrimSuffix(s, "G")
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("sysinfo: bad size %q", s)
+	}
+	return n * mult, nil
+}
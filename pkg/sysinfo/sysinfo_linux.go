@@ -0,0 +1,138 @@
+package sysinfo
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Caches lists the distinct cache instances of the given level, from
+// /sys/devices/system/cpu/cpu*/cache
+func Caches(level int) []Cache {
+	dirs, _ := filepath.Glob("/sys/devices/system/cpu/cpu[0-9]*/cache/index[0-9]*")
+	seen := map[string]bool{}
+	var caches []Cache
+	for _, dir := range dirs {
+		if readInt(filepath.Join(dir, "level")) != level {
+			continue
+		}
+		if t := readString(filepath.Join(dir, "type")); t == "Instruction" {
+			continue
+		}
+		list := readString(filepath.Join(dir, "shared_cpu_list"))
+		if seen[list] {
+			continue
+		}
+		seen[list] = true
+		size, err := parseSize(readString(filepath.Join(dir, "size")))
+		if err != nil {
+			continue
+		}
+		cpus, err := ParseCPUList(list)
+		if err != nil {
+			continue
+		}
+		caches = append(caches, Cache{Level: level, Size: size, CPUs: cpus})
+	}
+	sort.Slice(caches, func(i, j int) bool { return caches[i].CPUs[0] < caches[j].CPUs[0] })
+	return caches
+}
+
+// NUMANodes lists the NUMA nodes that have CPUs, from
+// /sys/devices/system/node
+func NUMANodes() []Node {
+	dirs, _ := filepath.Glob("/sys/devices/system/node/node[0-9]*")
+	var nodes []Node
+	for _, dir := range dirs {
+		id, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "node"))
+		if err != nil {
+			continue
+		}
+		cpus, err := ParseCPUList(readString(filepath.Join(dir, "cpulist")))
+		if err != nil || len(cpus) == 0 {
+			continue
+		}
+		nodes = append(nodes, Node{ID: id, CPUs: cpus})
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes
+}
+
+// ReadMemory returns total and available memory from /proc/meminfo
+func ReadMemory() Memory {
+	var mem Memory
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return mem
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 3 || fields[2] != "kB" {
+			continue
+		}
+		kb, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "MemTotal:":
+			mem.Total = kb << 10
+		case "MemAvailable:":
+			mem.Available = kb << 10
+		}
+	}
+	return mem
+}
+
+// ReadCPUTimes returns the CPU times of the whole system from /proc/stat
+func ReadCPUTimes() CPUTimes {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return CPUTimes{}
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	if !sc.Scan() {
+		return CPUTimes{}
+	}
+	t, _ := parseCPUTimes(sc.Text())
+	return t
+}
+
+// ReadProcessCPU returns the CPU time this process has used, in clock
+// ticks, from /proc/self/stat
+func ReadProcessCPU() uint64 {
+	n, _ := parseProcessCPU(readString("/proc/self/stat"))
+	return n
+}
+
+// ReadLoadAvg returns the 1-minute load average from /proc/loadavg
+func ReadLoadAvg() float64 {
+	fields := strings.Fields(readString("/proc/loadavg"))
+	if len(fields) == 0 {
+		return 0
+	}
+	load, _ := strconv.ParseFloat(fields[0], 64)
+	return load
+}
+
+func readString(path string) string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func readInt(path string) int {
+	n, err := strconv.Atoi(readString(path))
+	if err != nil {
+		return -1
+	}
+	return n
+}
@@ -0,0 +1,33 @@
+//go:build !linux
+
+package sysinfo
+
+// Caches is not implemented outside Linux
+func Caches(level int) []Cache {
+	return nil
+}
+
+// NUMANodes is not implemented outside Linux
+func NUMANodes() []Node {
+	return nil
+}
+
+// ReadMemory is not implemented outside Linux
+func ReadMemory() Memory {
+	return Memory{}
+}
+
+// ReadCPUTimes is not implemented outside Linux
+func ReadCPUTimes() CPUTimes {
+	return CPUTimes{}
+}
+
+// ReadProcessCPU is not implemented outside Linux
+func ReadProcessCPU() uint64 {
+	return 0
+}
+
+// ReadLoadAvg is not implemented outside Linux
+func ReadLoadAvg() float64 {
+	return 0
+}
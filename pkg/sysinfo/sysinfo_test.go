@@ -0,0 +1,89 @@
+package sysinfo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCPUList(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []int
+	}{
+		{"0", []int{0}},
+		{"0-3", []int{0, 1, 2, 3}},
+		{"0-1,8,10-11\n", []int{0, 1, 8, 10, 11}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		got, err := ParseCPUList(tt.in)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseCPUList(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"a", "3-1", "1-x"} {
+		if _, err := ParseCPUList(bad); err == nil {
+			t.Errorf("ParseCPUList(%q): expected error", bad)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]uint64{
+		"48K":      48 << 10,
+		"32768K":   32 << 20,
+		"2M":       2 << 20,
+		"1G":       1 << 30,
+		"512":      512,
+		" 307200K": 300 << 20,
+	}
+	for in, want := range tests {
+		if got, err := parseSize(in); err != nil || got != want {
+			t.Errorf("parseSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+}
+
+func TestNodeOf(t *testing.T) {
+	nodes := []Node{{ID: 0, CPUs: []int{0, 1, 2, 3}}, {ID: 1, CPUs: []int{4, 5, 6, 7}}}
+	tests := []struct {
+		cpus []int
+		want int
+	}{
+		{[]int{1, 2}, 0},
+		{[]int{4, 7}, 1},
+		{[]int{3, 4}, -1},
+		{[]int{9}, -1},
+		{nil, -1},
+	}
+	for _, tt := range tests {
+		if got := NodeOf(nodes, tt.cpus); got != tt.want {
+			t.Errorf("NodeOf(%v) = %d, want %d", tt.cpus, got, tt.want)
+		}
+	}
+}
+
+func TestParseCPUTimes(t *testing.T) {
+	got, err := parseCPUTimes("cpu  100 5 50 800 40 3 2 0 7 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Busy leaves out idle (800) and iowait (40); guest (7) is in user
+	if want := (CPUTimes{Busy: 160, Total: 1000}); got != want {
+		t.Fatalf("parseCPUTimes = %+v, want %+v", got, want)
+	}
+	if _, err := parseCPUTimes("cpu0 1 2 3 4 5"); err == nil {
+		t.Fatal("per-CPU line: expected error")
+	}
+}
+
+func TestParseProcessCPU(t *testing.T) {
+	// The command name may hold spaces and parentheses
+	stat := "4242 (mi ner) (x)) R 1 4242 4242 0 -1 4194304 120 0 0 0 1500 250 0 0 20 0 9 0 100 0 0"
+	if got, err := parseProcessCPU(stat); err != nil || got != 1750 {
+		t.Fatalf("parseProcessCPU = %d, %v; want 1750", got, err)
+	}
+	if _, err := parseProcessCPU("4242 (miner) R 1"); err == nil {
+		t.Fatal("short stat: expected error")
+	}
+}
@@ -0,0 +1,114 @@
+// Package thermal reads CPU temperatures from Linux hwmon sensors and
+// decides how far to throttle the miner to keep them in bounds.
+package thermal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// HwmonRoot is where the kernel lists hwmon devices
+const HwmonRoot = "/sys/class/hwmon"
+
+// ErrNoSensor is returned by FindSensor when no CPU sensor is found
+var ErrNoSensor = errors.New("thermal: no CPU temperature sensor found")
+
+// cpuDrivers are hwmon device names of CPU temperature sensors, best first
+var cpuDrivers = []string{"k10temp", "zenpower", "coretemp", "cpu_thermal", "soc_thermal", "acpitz"}
+
+// packageLabels are labels of a whole-package reading, preferred over
+// per-core ones
+var packageLabels = []string{"Tctl", "Tdie", "Package id 0", "CPU Temperature"}
+
+// FindSensor returns the temp*_input file of the CPU's package sensor
+// under root, a directory laid out like /sys/class/hwmon
+func FindSensor(root string) (string, error) {
+	devs, _ := filepath.Glob(filepath.Join(root, "hwmon*"))
+	sort.Strings(devs)
+	for _, driver := range cpuDrivers {
+		for _, dev := range devs {
+			if readString(filepath.Join(dev, "name")) != driver {
+				continue
+			}
+			inputs, _ := filepath.Glob(filepath.Join(dev, "temp*_input"))
+			if len(inputs) == 0 {
+				continue
+			}
+			sort.Strings(inputs)
+			for _, label := range packageLabels {
+				for _, in := range inputs {
+					if readString(strings.TrimSuffix(in, "_input")+"_label") == label {
+						return in, nil
+					}
+				}
+			}
+			return inputs[0], nil
+		}
+	}
+	return "", ErrNoSensor
+}
+
+// ReadTemp reads a temp*_input file, in millidegrees, as degrees Celsius
+func ReadTemp(path string) (float64, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	milli, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("thermal: bad reading in %s: %w", path, err)
+	}
+	return float64(milli) / 1000, nil
+}
+
+func readString(path string) string {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// Config bounds the temperature, in degrees Celsius
+type Config struct {
+	High  float64 // throttle one step further on each reading at or above
+	Low   float64 // ease one step on each reading at or below
+	Steps int     // throttle levels; at the last, Fraction is 1/(Steps+1)
+}
+
+// Controller steps the throttle level with the temperature. Between Low
+// and High the level holds, so it settles instead of oscillating. It is
+// not safe for concurrent use.
+type Controller struct {
+	cfg   Config
+	level int
+}
+
+// NewController returns a controller at level 0, not throttling
+func NewController(cfg Config) *Controller {
+	if cfg.Steps < 1 {
+		cfg.Steps = 1
+	}
+	return &Controller{cfg: cfg}
+}
+
+// Update takes a reading and returns the new throttle level
+func (c *Controller) Update(temp float64) int {
+	switch {
+	case temp >= c.cfg.High && c.level < c.cfg.Steps:
+		c.level++
+	case temp <= c.cfg.Low && c.level > 0:
+		c.level--
+	}
+	return c.level
+}
+
+// Fraction is the share of full mining allowed at the current level
+func (c *Controller) Fraction() float64 {
+	return 1 - float64(c.level)/float64(c.cfg.Steps+1)
+}
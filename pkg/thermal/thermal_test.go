@@ -0,0 +1,78 @@
+package thermal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeHwmon builds a sysfs-like tree of hwmon devices under a temp dir:
+// name -> attribute file -> contents
+func fakeHwmon(t *testing.T, devs map[string]map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for dev, files := range devs {
+		dir := filepath.Join(root, dev)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content+"\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return root
+}
+
+func TestFindSensor(t *testing.T) {
+	root := fakeHwmon(t, map[string]map[string]string{
+		"hwmon0": {"name": "nvme", "temp1_input": "40000"},
+		"hwmon1": {
+			"name":        "coretemp",
+			"temp1_label": "Core 0", "temp1_input": "61000",
+			"temp2_label": "Package id 0", "temp2_input": "67500",
+		},
+	})
+	path, err := FindSensor(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(root, "hwmon1", "temp2_input"); path != want {
+		t.Fatalf("FindSensor = %s, want the package sensor %s", path, want)
+	}
+	if temp, err := ReadTemp(path); err != nil || temp != 67.5 {
+		t.Fatalf("ReadTemp = %v, %v; want 67.5", temp, err)
+	}
+
+	// Unlabelled sensors fall back to the first input
+	root = fakeHwmon(t, map[string]map[string]string{
+		"hwmon3": {"name": "k10temp", "temp1_input": "55000", "temp3_input": "50000"},
+	})
+	if path, err := FindSensor(root); err != nil || filepath.Base(path) != "temp1_input" {
+		t.Fatalf("FindSensor = %s, %v; want temp1_input", path, err)
+	}
+
+	if _, err := FindSensor(fakeHwmon(t, map[string]map[string]string{"hwmon0": {"name": "nvme"}})); err != ErrNoSensor {
+		t.Fatalf("no CPU sensor: got %v, want ErrNoSensor", err)
+	}
+}
+
+func TestController(t *testing.T) {
+	c := NewController(Config{High: 85, Low: 75, Steps: 3})
+	for i, step := range []struct {
+		temp  float64
+		level int
+	}{
+		{70, 0}, {86, 1}, {88, 2}, {80, 2}, {90, 3}, {95, 3}, {74, 2}, {80, 2}, {70, 1}, {60, 0}, {60, 0},
+	} {
+		if got := c.Update(step.temp); got != step.level {
+			t.Fatalf("step %d: %v°C gave level %d, want %d", i, step.temp, got, step.level)
+		}
+	}
+	c.Update(99)
+	c.Update(99)
+	if f := c.Fraction(); f != 0.5 {
+		t.Fatalf("Fraction at level 2 of 3 = %v, want 0.5", f)
+	}
+}
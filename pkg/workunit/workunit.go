@@ -0,0 +1,166 @@
+// Package workunit splits a job's search space into non-overlapping units
+// of work. The space is every extranonce2 the pool allows times a nonce
+// space under each; a unit is one extranonce2 and a nonce range under it.
+package workunit
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrExhausted is returned by Next once every unit of the job is handed out
+var ErrExhausted = errors.New("workunit: search space exhausted")
+
+// maxJobs bounds how many jobs' cursors are kept for resuming
+const maxJobs = 16
+
+// Unit is one slice of a job's search space
+type Unit struct {
+	JobID       string
+	ExtraNonce2 uint64
+	BaseNonce   uint32
+	NonceRange  uint32
+}
+
+// Job identifies a job's search space. Units of the same job are only
+// resumed, never repeated, while ExtraNonce1 stays the same.
+type Job struct {
+	ID              string
+	ExtraNonce1     string
+	ExtraNonce2Size int // bytes
+}
+
+// Allocator hands out units of the current job in order: the whole nonce
+// space of an extranonce2 before moving on to the next extranonce2. Units
+// handed back unfinished are handed out again first. It is safe for
+// concurrent use.
+type Allocator struct {
+	noncesPerEN2 uint64
+
+	mu      sync.Mutex
+	job     Job
+	cur     *cursor
+	cursors map[Job]*cursor
+	order   []Job // oldest first, for evicting cursors
+}
+
+// cursor is the next unit of a job
+type cursor struct {
+	en2       uint64
+	nonce     uint64 // next nonce under en2
+	maxEN2    uint64 // last extranonce2 the pool's size allows
+	exhausted bool
+	returned  []Unit // handed back unfinished
+}
+
+// NewAllocator returns an allocator searching noncesPerEN2 nonces under
+// each extranonce2. Zero means the full 32-bit nonce space.
+func NewAllocator(noncesPerEN2 uint64) *Allocator {
+	if noncesPerEN2 == 0 || noncesPerEN2 > 1<<32 {
+		noncesPerEN2 = 1 << 32
+	}
+	return &Allocator{
+		noncesPerEN2: noncesPerEN2,
+		cursors:      make(map[Job]*cursor),
+	}
+}
+
+// Start switches to job. A job seen before resumes where it left off,
+// unless clean is set: then, as with stratum's clean_jobs, every earlier
+// job is forgotten.
+func (a *Allocator) Start(job Job, clean bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if clean {
+		clear(a.cursors)
+		a.order = a.order[:0]
+	}
+	a.job = job
+	if c, ok := a.cursors[job]; ok {
+		a.cur = c
+		return
+	}
+
+	c := &cursor{maxEN2: ^uint64(0)}
+	if job.ExtraNonce2Size < 8 {
+		c.maxEN2 = 1<<(8*max(job.ExtraNonce2Size, 0)) - 1
+	}
+	a.cur = c
+	a.cursors[job] = c
+	a.order = append(a.order, job)
+	if len(a.order) > maxJobs {
+		delete(a.cursors, a.order[0])
+		a.order = a.order[1:]
+	}
+}
+
+// Next returns the next unit of the current job with at most n nonces, or
+// ErrExhausted when there is none left (or no job was started). Units never
+// straddle two extranonce2 values, so they may be shorter than n.
+func (a *Allocator) Next(n uint32) (Unit, error) {
+	n = max(n, 1)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	c := a.cur
+	if c == nil {
+		return Unit{}, ErrExhausted
+	}
+
+	if k := len(c.returned); k > 0 {
+		u := c.returned[k-1]
+		if u.NonceRange <= n {
+			c.returned = c.returned[:k-1]
+			return u, nil
+		}
+		c.returned[k-1].BaseNonce += n
+		c.returned[k-1].NonceRange -= n
+		u.NonceRange = n
+		return u, nil
+	}
+	if c.exhausted {
+		return Unit{}, ErrExhausted
+	}
+
+	size := min(uint64(n), a.noncesPerEN2-c.nonce)
+	u := Unit{
+		JobID:       a.job.ID,
+		ExtraNonce2: c.en2,
+		BaseNonce:   uint32(c.nonce),
+		NonceRange:  uint32(size),
+	}
+	if c.nonce += size; c.nonce == a.noncesPerEN2 {
+		c.nonce = 0
+		if c.en2 == c.maxEN2 {
+			c.exhausted = true
+		} else {
+			c.en2++
+		}
+	}
+	return u, nil
+}
+
+// Return hands back the part of u past its first searched nonces, to be
+// handed out again by Next. Units of jobs no longer tracked are dropped.
+func (a *Allocator) Return(u Unit, searched uint32) {
+	if searched >= u.NonceRange {
+		return
+	}
+	u.BaseNonce += searched
+	u.NonceRange -= searched
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	c := a.cur
+	if a.job.ID != u.JobID {
+		c = nil
+		// Newest first: a job ID may recur under another extranonce1
+		for i := len(a.order) - 1; i >= 0 && c == nil; i-- {
+			if a.order[i].ID == u.JobID {
+				c = a.cursors[a.order[i]]
+			}
+		}
+	}
+	if c != nil {
+		c.returned = append(c.returned, u)
+	}
+}
@@ -0,0 +1,126 @@
+package workunit
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestAllocatorOrder(t *testing.T) {
+	a := NewAllocator(4096)
+	a.Start(Job{ID: "a", ExtraNonce2Size: 4}, false)
+	sizes := []uint32{1024, 1024, 1500, 1000, 0, 1024}
+	want := []Unit{
+		{"a", 0, 0, 1024}, {"a", 0, 1024, 1024}, {"a", 0, 2048, 1500}, {"a", 0, 3548, 548},
+		{"a", 1, 0, 1}, {"a", 1, 1, 1024},
+	}
+	for i, w := range want {
+		if u, err := a.Next(sizes[i]); err != nil || u != w {
+			t.Fatalf("unit %d = %+v, %v; want %+v", i, u, err, w)
+		}
+	}
+}
+
+func TestAllocatorExhaustion(t *testing.T) {
+	a := NewAllocator(0)
+	if _, err := a.Next(1 << 31); !errors.Is(err, ErrExhausted) {
+		t.Fatalf("Next before Start: got %v, want ErrExhausted", err)
+	}
+	// One extranonce2 byte: 256 values, two ranges each
+	a.Start(Job{ID: "a", ExtraNonce2Size: 1}, false)
+	for i := 0; i < 512; i++ {
+		if _, err := a.Next(1 << 31); err != nil {
+			t.Fatalf("unit %d: %v", i, err)
+		}
+	}
+	if _, err := a.Next(1 << 31); !errors.Is(err, ErrExhausted) {
+		t.Fatalf("got %v, want ErrExhausted", err)
+	}
+}
+
+func TestAllocatorResume(t *testing.T) {
+	a := NewAllocator(1)
+	ja := Job{ID: "a", ExtraNonce1: "01", ExtraNonce2Size: 4}
+	jb := Job{ID: "b", ExtraNonce1: "01", ExtraNonce2Size: 4}
+
+	a.Start(ja, false)
+	a.Next(1)
+	a.Next(1)
+	a.Start(jb, false)
+	a.Next(1)
+	a.Start(ja, false)
+	if u, _ := a.Next(1); u.ExtraNonce2 != 2 {
+		t.Fatalf("resumed job a at extranonce2 %d, want 2", u.ExtraNonce2)
+	}
+
+	// A new extranonce1 is a fresh search space
+	a.Start(Job{ID: "a", ExtraNonce1: "02", ExtraNonce2Size: 4}, false)
+	if u, _ := a.Next(1); u.ExtraNonce2 != 0 {
+		t.Fatalf("new extranonce1 started at %d, want 0", u.ExtraNonce2)
+	}
+
+	// clean_jobs forgets earlier jobs
+	a.Start(ja, true)
+	if u, _ := a.Next(1); u.ExtraNonce2 != 0 {
+		t.Fatalf("after clean, job a started at %d, want 0", u.ExtraNonce2)
+	}
+}
+
+func TestAllocatorReturn(t *testing.T) {
+	a := NewAllocator(0)
+	a.Start(Job{ID: "a", ExtraNonce2Size: 4}, false)
+	u, _ := a.Next(100)
+	a.Return(u, 30)
+	a.Return(Unit{JobID: "gone", NonceRange: 10}, 0)
+
+	// The unsearched tail comes back first, split to the size asked for
+	if got, _ := a.Next(50); got != (Unit{"a", 0, 30, 50}) {
+		t.Fatalf("first unit after Return = %+v", got)
+	}
+	if got, _ := a.Next(50); got != (Unit{"a", 0, 80, 20}) {
+		t.Fatalf("second unit after Return = %+v", got)
+	}
+	if got, _ := a.Next(50); got != (Unit{"a", 0, 100, 50}) {
+		t.Fatalf("fresh unit after Return = %+v", got)
+	}
+
+	// Tails of a job restarted later are not lost
+	u, _ = a.Next(10)
+	a.Start(Job{ID: "b", ExtraNonce2Size: 4}, false)
+	a.Return(u, 0)
+	a.Start(Job{ID: "a", ExtraNonce2Size: 4}, false)
+	if got, _ := a.Next(10); got != u {
+		t.Fatalf("resumed job a with %+v, want returned %+v", got, u)
+	}
+}
+
+func TestAllocatorConcurrent(t *testing.T) {
+	a := NewAllocator(1 << 20)
+	a.Start(Job{ID: "a", ExtraNonce2Size: 8}, false)
+
+	var (
+		mu   sync.Mutex
+		seen = map[Unit]bool{}
+		wg   sync.WaitGroup
+	)
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				u, err := a.Next(1024)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				mu.Lock()
+				if seen[u] {
+					t.Errorf("unit %+v handed out twice", u)
+				}
+				seen[u] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+}
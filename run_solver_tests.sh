@@ -38,6 +38,19 @@ else
 fi
 
 cd ../..
+
+# Test 4: Cancellation stress under the race detector and ASan
+echo "4. Cancellation stress (-race)..."
+go test -race -run 'Cancel|Close|Concurrent' ./pkg/solver
+
+echo "5. Cancellation stress (ASan)..."
+make -C solver/tromp asan
+go test -asan -run 'Cancel|Close|Concurrent' ./pkg/solver
+make -C solver/tromp clean all
+
+echo "6. Pure-Go solver (-tags purego)..."
+go test -tags purego ./pkg/solver
+
 echo
 echo "=== Tests Complete ==="
 echo
@@ -0,0 +1,316 @@
+// Cuckoo Cycle solver C API
+// Context lifecycle, cancellation and diagnostics shared by all backends and
+// graph sizes; solving and verification are dispatched to the variant built
+// for the context's backend/edgebits/proofsize (see cuckoo_lean.cpp,
+// cuckoo_mean.cpp and cuckoo_variants.h).
+
+#include "cuckoo_internal.h"
+#include "cuckoo_variants.h"
+#include <string.h>
+#include <stdlib.h>
+#include <stdio.h>
+#include <stdarg.h>
+#include <mutex>
+#include <new>
+
+#include "cuckoo-orig/src/crypto/blake2b-ref.c"
+
+// Per-variant entry points exported by the cuckoo_lean.cpp/cuckoo_mean.cpp objects
+#define DECLARE_VARIANT(prefix, eb, ps) \
+    extern "C" void* VARIANT_FN(prefix##_create, eb, ps)(uint32_t nthreads, hugepage_arena* arena); \
+    extern "C" void VARIANT_FN(prefix##_destroy, eb, ps)(void* impl); \
+    extern "C" void VARIANT_FN(prefix##_abort, eb, ps)(void* impl); \
+    extern "C" int VARIANT_FN(prefix##_solve, eb, ps)(solver_ctx* ctx, void* impl); \
+    extern "C" int VARIANT_FN(prefix##_verify, eb, ps)(const uint8_t* header, uint32_t header_len, \
+                                                       uint32_t nonce, const uint32_t* proof); \
+    extern "C" uint64_t VARIANT_FN(prefix##_membytes, eb, ps)(uint32_t nthreads);
+#define DECLARE_LEAN_VARIANT(eb, ps) DECLARE_VARIANT(lean, eb, ps)
+#define DECLARE_MEAN_VARIANT(eb, ps) DECLARE_VARIANT(mean, eb, ps)
+CUCKOO_LEAN_VARIANTS(DECLARE_LEAN_VARIANT)
+CUCKOO_MEAN_VARIANTS(DECLARE_MEAN_VARIANT)
+
+#define VARIANT_ENTRY(backend, prefix, eb, ps) \
+    { backend, eb, ps, VARIANT_FN(prefix##_create, eb, ps), VARIANT_FN(prefix##_destroy, eb, ps), \
+      VARIANT_FN(prefix##_abort, eb, ps), VARIANT_FN(prefix##_solve, eb, ps), \
+      VARIANT_FN(prefix##_verify, eb, ps), VARIANT_FN(prefix##_membytes, eb, ps) },
+#define LEAN_VARIANT_ENTRY(eb, ps) VARIANT_ENTRY(CUCKOO_BACKEND_LEAN, lean, eb, ps)
+#define MEAN_VARIANT_ENTRY(eb, ps) VARIANT_ENTRY(CUCKOO_BACKEND_MEAN, mean, eb, ps)
+
+static const cuckoo_variant variants[] = {
+    CUCKOO_LEAN_VARIANTS(LEAN_VARIANT_ENTRY)
+    CUCKOO_MEAN_VARIANTS(MEAN_VARIANT_ENTRY)
+};
+static const int nvariants = sizeof(variants) / sizeof(variants[0]);
+
+static const cuckoo_variant* find_variant(uint32_t backend, uint32_t edgebits, uint32_t proofsize) {
+    for (int i = 0; i < nvariants; i++) {
+        if (variants[i].backend == backend && variants[i].edgebits == edgebits &&
+            variants[i].proofsize == proofsize) {
+            return &variants[i];
+        }
+    }
+    return NULL;
+}
+
+// Any variant for the graph will do for verification
+static const cuckoo_variant* find_graph(uint32_t edgebits, uint32_t proofsize) {
+    for (int i = 0; i < nvariants; i++) {
+        if (variants[i].edgebits == edgebits && variants[i].proofsize == proofsize) {
+            return &variants[i];
+        }
+    }
+    return NULL;
+}
+
+// Wrapper context that holds the variant's solver state.
+// Owned by solver_ctx::internal from cuckoo_alloc until cuckoo_free, so the
+// (large) solver allocation is reused across cuckoo_solve calls and
+// cuckoo_abort never sees it disappear underneath it.
+struct internal_ctx {
+    std::mutex mu;                  // guards variant/impl against cuckoo_abort
+    const cuckoo_variant* variant;  // variant impl was created by
+    void* impl;
+    uint32_t nthreads;              // thread count impl was built for
+    uint32_t hugepages;             // CUCKOO_HUGEPAGES_* impl was allocated with
+    hugepage_arena arena;           // impl's huge page backed buffers
+    solver_ctx* api_ctx;
+
+    internal_ctx(solver_ctx* ctx) : variant(NULL), impl(NULL), nthreads(0), hugepages(0), api_ctx(ctx) {}
+};
+
+static std::atomic<int> live_contexts(0);
+static std::atomic<uint64_t> impls_created(0), impls_destroyed(0);
+
+// Diagnostics sink; silent until cuckoo_set_logger installs one
+static std::atomic<cuckoo_log_fn> log_fn(NULL);
+std::atomic<int> cuckoo_log_level(CUCKOO_LOG_NONE);
+
+void cuckoo_log(int level, const char* fmt, ...) {
+    cuckoo_log_fn fn = log_fn.load();
+    if (!fn) return;
+    char msg[256];
+    va_list ap;
+    va_start(ap, fmt);
+    vsnprintf(msg, sizeof(msg), fmt, ap);
+    va_end(ap);
+    fn(level, msg);
+}
+
+// Drop the variant's solver state, keeping the wrapper itself
+static void release_impl(internal_ctx* ictx) {
+    std::lock_guard<std::mutex> lock(ictx->mu);
+    if (ictx->impl) {
+        ictx->variant->destroy(ictx->impl);
+        impls_destroyed.fetch_add(1);
+    }
+    ictx->variant = NULL;
+    ictx->impl = NULL;
+    ictx->nthreads = 0;
+    ictx->hugepages = 0;
+    ictx->arena.release();
+}
+
+// Make sure the solver state matches the requested graph size, threads and
+// huge page mode
+static bool ensure_impl(internal_ctx* ictx, const cuckoo_variant* v, uint32_t nthreads, uint32_t hugepages) {
+    if (ictx->impl && ictx->variant == v && ictx->nthreads == nthreads && ictx->hugepages == hugepages) {
+        return true;
+    }
+    release_impl(ictx);
+
+    // The variant puts its large buffers in the arena in create
+    ictx->arena.mode = hugepages;
+    void* impl = v->create(nthreads, &ictx->arena);
+    if (!impl) {
+        ictx->arena.release();
+        return false;
+    }
+    impls_created.fetch_add(1);
+
+    std::lock_guard<std::mutex> lock(ictx->mu);
+    ictx->variant = v;
+    ictx->impl = impl;
+    ictx->nthreads = nthreads;
+    ictx->hugepages = hugepages;
+    return true;
+}
+
+extern "C" {
+
+void cuckoo_set_logger(cuckoo_log_fn fn, int min_level) {
+    if (!fn) min_level = CUCKOO_LOG_NONE;
+    // Raise the level first so the hot path never sees a stale sink
+    cuckoo_log_level.store(CUCKOO_LOG_NONE);
+    log_fn.store(fn);
+    cuckoo_log_level.store(min_level);
+}
+
+solver_ctx* cuckoo_alloc(void) {
+    solver_ctx* ctx = (solver_ctx*)malloc(sizeof(solver_ctx));
+    if (!ctx) return NULL;
+    cuckoo_init(ctx);
+
+    internal_ctx* ictx = new (std::nothrow) internal_ctx(ctx);
+    if (!ictx) {
+        free(ctx);
+        return NULL;
+    }
+    ctx->internal = (void*)ictx;
+
+    live_contexts.fetch_add(1);
+    return ctx;
+}
+
+void cuckoo_free(solver_ctx* ctx) {
+    if (!ctx) return;
+    internal_ctx* ictx = (internal_ctx*)ctx->internal;
+    if (ictx) {
+        release_impl(ictx);
+        delete ictx;
+    }
+    ctx->internal = NULL;
+    free(ctx);
+    live_contexts.fetch_sub(1);
+}
+
+int cuckoo_live_contexts(void) {
+    return live_contexts.load();
+}
+
+void cuckoo_impl_counts(uint64_t* created, uint64_t* destroyed) {
+    *created = impls_created.load();
+    *destroyed = impls_destroyed.load();
+}
+
+void cuckoo_init(solver_ctx* ctx) {
+    memset(ctx, 0, sizeof(solver_ctx));
+    ctx->nthreads = 1;
+    ctx->backend = CUCKOO_BACKEND_LEAN;
+    ctx->edgebits = CUCKOO_DEFAULT_EDGEBITS;
+    ctx->proofsize = CUCKOO_DEFAULT_PROOFSIZE;
+    ctx->nonce_range = 1;
+    ctx->abort_flag = 0;
+    ctx->internal = NULL;
+}
+
+void cuckoo_setheader(solver_ctx* ctx, const uint8_t* header, uint32_t len) {
+    if (len > 80) len = 80;
+    memcpy(ctx->header, header, len);
+    ctx->header_len = len;
+}
+
+void cuckoo_sethdrkey(solver_ctx* ctx, const uint8_t* key32) {
+    // Store key in the beginning of header buffer for reuse
+    memcpy(ctx->header, key32, 32);
+    ctx->header_len = 32; // denotes key length for our use
+}
+
+int cuckoo_supported(uint32_t backend, uint32_t edgebits, uint32_t proofsize) {
+    return find_variant(backend, edgebits, proofsize) != NULL;
+}
+
+int cuckoo_variant_count(void) {
+    return nvariants;
+}
+
+int cuckoo_variant_params(int i, uint32_t* backend, uint32_t* edgebits, uint32_t* proofsize) {
+    if (i < 0 || i >= nvariants) return 0;
+    *backend = variants[i].backend;
+    *edgebits = variants[i].edgebits;
+    *proofsize = variants[i].proofsize;
+    return 1;
+}
+
+uint64_t cuckoo_membytes(uint32_t backend, uint32_t edgebits, uint32_t proofsize, uint32_t nthreads) {
+    const cuckoo_variant* v = find_variant(backend, edgebits, proofsize);
+    return v ? v->membytes(nthreads) : 0;
+}
+
+int cuckoo_solve(solver_ctx* ctx) {
+    CUCKOO_LOG(CUCKOO_LOG_DEBUG, "cuckoo_solve: starting, backend=%u, edgebits=%u, nthreads=%u, nonce=%u, range=%u",
+            ctx->backend, ctx->edgebits, ctx->nthreads, ctx->nonce, ctx->nonce_range);
+
+    ctx->solutions = 0;
+    ctx->graphs = 0;
+    memset(ctx->phase_ns, 0, sizeof(ctx->phase_ns));
+
+    const cuckoo_variant* v = find_variant(ctx->backend, ctx->edgebits, ctx->proofsize);
+    if (!v) {
+        CUCKOO_LOG(CUCKOO_LOG_ERROR, "cuckoo_solve: no solver built for backend=%u edgebits=%u proofsize=%u",
+                ctx->backend, ctx->edgebits, ctx->proofsize);
+        return 0;
+    }
+
+    // Contexts set up with cuckoo_init (not cuckoo_alloc) get a wrapper that
+    // lives only for this call. It is never published in ctx->internal, so
+    // cuckoo_abort on such a context only raises abort_flag, which is
+    // honoured between graphs.
+    internal_ctx* ictx = (internal_ctx*)ctx->internal;
+    bool transient = (ictx == NULL);
+    if (transient) {
+        ictx = new internal_ctx(ctx);
+    }
+
+    if (ctx->nthreads == 0 || !ensure_impl(ictx, v, ctx->nthreads, ctx->hugepages)) {
+        if (transient) {
+            delete ictx;
+        }
+        return 0;
+    }
+
+    int nsols = v->solve(ctx, ictx->impl);
+
+    // Transient wrappers are torn down; owned ones keep their memory
+    if (transient) {
+        release_impl(ictx);
+        delete ictx;
+    }
+
+    return nsols;
+}
+
+void cuckoo_abort(solver_ctx* ctx) {
+    if (!ctx) return;
+    set_abort(ctx, 1);
+    // Abort the running round as well. ctx->internal is only set for
+    // cuckoo_alloc contexts and stays valid until cuckoo_free.
+    internal_ctx* ictx = (internal_ctx*)ctx->internal;
+    if (!ictx) return;
+    std::lock_guard<std::mutex> lock(ictx->mu);
+    if (ictx->impl) {
+        ictx->variant->abort(ictx->impl);
+    }
+}
+
+void cuckoo_reset_abort(solver_ctx* ctx) {
+    if (!ctx) return;
+    set_abort(ctx, 0);
+}
+
+void cuckoo_hugepages(solver_ctx* ctx, cuckoo_hugepage_info* info) {
+    // Contexts without a wrapper (cuckoo_init) free their memory at the end
+    // of every solve
+    internal_ctx* ictx = ctx ? (internal_ctx*)ctx->internal : NULL;
+    if (!ictx) {
+        memset(info, 0, sizeof(*info));
+        return;
+    }
+    ictx->arena.usage(info);
+}
+
+int cuckoo_verify(uint32_t edgebits, uint32_t proofsize,
+                  const uint8_t* header, uint32_t header_len, uint32_t nonce, const uint32_t* proof) {
+    const cuckoo_variant* v = find_graph(edgebits, proofsize);
+    if (!v || header_len > 80) {
+        return 0;
+    }
+    return v->verify(header, header_len, nonce, proof);
+}
+
+void cuckoo_sha256d(const uint8_t* data, size_t len, uint8_t* hash) {
+    // Use blake2b for now, should be replaced with actual SHA256d
+    blake2b(hash, 32, data, len, NULL, 0);
+    blake2b(hash, 32, hash, 32, NULL, 0);
+}
+
+} // extern "C"
@@ -0,0 +1,197 @@
+// Huge page backing for the solvers' large buffers
+//
+// Tromp's solvers allocate their bitmaps and buckets in their constructors,
+// which we don't patch. The lean solver's malloc and calloc reach the
+// context's hugepage_arena through an allocator hook (see hugepage_scope);
+// the mean solver's new[]'d buckets are replaced right after construction
+// (see hugepage_replace). The arena hands out buffers of at least
+// CUCKOO_HUGEPAGE_SIZE bytes, mmap'd either from the hugetlbfs pool or 2 MiB
+// aligned and advised for transparent huge pages. Everything else, and
+// everything when huge pages are off or unavailable, stays on the heap.
+
+#include "cuckoo_internal.h"
+#include <stdint.h>
+#include <stdlib.h>
+#include <stdio.h>
+#include <string.h>
+
+#if defined(__linux__)
+#include <sys/mman.h>
+
+// Map len bytes (a multiple of CUCKOO_HUGEPAGE_SIZE) as mode asks, falling
+// back from hugetlb to THP to plain pages
+static void* map_region(size_t len, uint32_t mode, int* kind) {
+#ifdef MAP_HUGETLB
+    if (mode == CUCKOO_HUGEPAGES_HUGETLB) {
+        void* p = mmap(NULL, len, PROT_READ | PROT_WRITE,
+                       MAP_PRIVATE | MAP_ANONYMOUS | MAP_HUGETLB, -1, 0);
+        if (p != MAP_FAILED) {
+            *kind = REGION_HUGETLB;
+            return p;
+        }
+        CUCKOO_LOG(CUCKOO_LOG_WARN, "hugepages: no hugetlb pages for %zu bytes, falling back to THP", len);
+    }
+#endif
+    // Over-allocate by one huge page and trim, so the region is aligned
+    size_t span = len + CUCKOO_HUGEPAGE_SIZE;
+    char* raw = (char*)mmap(NULL, span, PROT_READ | PROT_WRITE, MAP_PRIVATE | MAP_ANONYMOUS, -1, 0);
+    if (raw == (char*)MAP_FAILED) {
+        return NULL;
+    }
+    char* p = (char*)(((uintptr_t)raw + CUCKOO_HUGEPAGE_SIZE - 1) & ~(uintptr_t)(CUCKOO_HUGEPAGE_SIZE - 1));
+    if (p > raw) {
+        munmap(raw, p - raw);
+    }
+    if (raw + span > p + len) {
+        munmap(p + len, raw + span - (p + len));
+    }
+    *kind = REGION_PLAIN;
+#ifdef MADV_HUGEPAGE
+    if (madvise(p, len, MADV_HUGEPAGE) == 0) {
+        *kind = REGION_THP;
+    } else {
+        CUCKOO_LOG(CUCKOO_LOG_WARN, "hugepages: madvise(MADV_HUGEPAGE) failed, using small pages");
+    }
+#endif
+    return p;
+}
+
+static void unmap_region(void* p, size_t len) {
+    munmap(p, len);
+}
+
+#else
+
+static void* map_region(size_t, uint32_t, int*) {
+    return NULL;
+}
+
+static void unmap_region(void*, size_t) {}
+
+#endif
+
+bool hugepage_arena::takes(size_t n) const {
+    return mode != CUCKOO_HUGEPAGES_OFF && n >= CUCKOO_HUGEPAGE_SIZE;
+}
+
+void* hugepage_arena::alloc(size_t n) {
+    if (!takes(n)) {
+        return NULL;
+    }
+    size_t len = (n + CUCKOO_HUGEPAGE_SIZE - 1) & ~(size_t)(CUCKOO_HUGEPAGE_SIZE - 1);
+    int kind;
+    void* p = map_region(len, mode, &kind);
+    if (!p) {
+        return NULL;
+    }
+    std::lock_guard<std::mutex> lock(mu);
+    regions.push_back(hugepage_region{p, len, kind});
+    return p;
+}
+
+bool hugepage_arena::owns(const void* p) {
+    std::lock_guard<std::mutex> lock(mu);
+    for (const hugepage_region& r : regions) {
+        if (r.addr == p) return true;
+    }
+    return false;
+}
+
+void hugepage_arena::release() {
+    std::lock_guard<std::mutex> lock(mu);
+    for (const hugepage_region& r : regions) {
+        unmap_region(r.addr, r.len);
+    }
+    regions.clear();
+}
+
+// The arena Tromp's allocations on this thread go to, if any
+static thread_local hugepage_arena* scope_arena = NULL;
+
+hugepage_scope::hugepage_scope(hugepage_arena* arena) : prev(scope_arena) {
+    scope_arena = arena;
+}
+
+hugepage_scope::~hugepage_scope() {
+    scope_arena = prev;
+}
+
+void* tromp_malloc(size_t n) {
+    void* p = scope_arena ? scope_arena->alloc(n) : NULL;
+    return p ? p : malloc(n);
+}
+
+void* tromp_calloc(size_t n, size_t size) {
+    if (size && n > SIZE_MAX / size) return NULL;
+    void* p = scope_arena ? scope_arena->alloc(n * size) : NULL;
+    return p ? p : calloc(n, size);
+}
+
+void tromp_free(void* p) {
+    // release unmaps the arena's buffers
+    if (p && scope_arena && scope_arena->owns(p)) return;
+    free(p);
+}
+
+#if defined(__linux__)
+
+// Bytes of the regions rs backed by transparent huge pages, from
+// /proc/self/smaps. A VMA's AnonHugePages is shared out by overlap, since
+// adjacent advised regions may have been merged into one VMA.
+static uint64_t thp_resident(const hugepage_region* rs, int n) {
+    FILE* f = fopen("/proc/self/smaps", "r");
+    if (!f) return 0;
+    uint64_t total = 0;
+    uintptr_t lo = 0, hi = 0;
+    // Long enough for a VMA header with a PATH_MAX path
+    char line[4200];
+    while (fgets(line, sizeof(line), f)) {
+        unsigned long a, b, kb;
+        // Only VMA header lines ("start-end perms ...") scan as two numbers
+        if (sscanf(line, "%lx-%lx", &a, &b) == 2) {
+            lo = a;
+            hi = b;
+            continue;
+        }
+        if (sscanf(line, "AnonHugePages: %lu kB", &kb) != 1 || kb == 0 || hi <= lo) {
+            continue;
+        }
+        for (int i = 0; i < n; i++) {
+            uintptr_t rlo = (uintptr_t)rs[i].addr, rhi = rlo + rs[i].len;
+            uintptr_t olo = rlo > lo ? rlo : lo, ohi = rhi < hi ? rhi : hi;
+            if (ohi > olo) {
+                total += (uint64_t)((double)kb * 1024 * (ohi - olo) / (hi - lo));
+            }
+        }
+    }
+    fclose(f);
+    return total;
+}
+
+#else
+
+static uint64_t thp_resident(const hugepage_region*, int) {
+    return 0;
+}
+
+#endif
+
+void hugepage_arena::usage(cuckoo_hugepage_info* info) {
+    memset(info, 0, sizeof(*info));
+    // Copy the THP regions out so smaps is read without the lock
+    std::vector<hugepage_region> thp;
+    {
+        std::lock_guard<std::mutex> lock(mu);
+        for (const hugepage_region& r : regions) {
+            info->large_bytes += r.len;
+            if (r.kind == REGION_HUGETLB) {
+                info->hugetlb_bytes += r.len;
+            } else if (r.kind == REGION_THP) {
+                thp.push_back(r);
+            }
+        }
+    }
+    if (!thp.empty()) {
+        info->thp_bytes = thp_resident(thp.data(), (int)thp.size());
+    }
+}
@@ -0,0 +1,142 @@
+// Internal glue shared by the C API (cuckoo_api.cpp) and the per-variant
+// solver objects (cuckoo_lean.cpp, cuckoo_mean.cpp). Not part of the Go-facing interface.
+
+#ifndef CUCKOO_INTERNAL_H
+#define CUCKOO_INTERNAL_H
+
+#include "cuckoo_lean.h"
+#include <atomic>
+#include <mutex>
+#include <new>
+#include <type_traits>
+#include <vector>
+#include <time.h>
+
+// Diagnostics; defined in cuckoo_api.cpp
+extern std::atomic<int> cuckoo_log_level;
+void cuckoo_log(int level, const char* fmt, ...) __attribute__((format(printf, 2, 3)));
+
+// Level check stays inline so disabled messages cost one relaxed load
+#define CUCKOO_LOG(level, ...) \
+    do { \
+        if ((level) >= cuckoo_log_level.load(std::memory_order_relaxed)) \
+            cuckoo_log((level), __VA_ARGS__); \
+    } while (0)
+
+// abort_flag lives in a plain C struct shared with Go, so it is only ever
+// touched through these atomic helpers
+static inline bool abort_requested(const solver_ctx* ctx) {
+    return __atomic_load_n(&ctx->abort_flag, __ATOMIC_ACQUIRE) != 0;
+}
+
+static inline void set_abort(solver_ctx* ctx, uint32_t v) {
+    __atomic_store_n(&ctx->abort_flag, v, __ATOMIC_RELEASE);
+}
+
+// Monotonic clock for solver_ctx.phase_ns
+static inline uint64_t cuckoo_now_ns(void) {
+    struct timespec ts;
+    clock_gettime(CLOCK_MONOTONIC, &ts);
+    return (uint64_t)ts.tv_sec * 1000000000ull + (uint64_t)ts.tv_nsec;
+}
+
+// Huge page backed allocation (cuckoo_hugepages.cpp)
+#define CUCKOO_HUGEPAGE_SIZE (2u << 20)
+
+// Kinds of mmap'd region
+#define REGION_PLAIN   0  // mmap'd but neither advice nor hugetlb took
+#define REGION_THP     1
+#define REGION_HUGETLB 2
+
+struct hugepage_region {
+    void* addr;
+    size_t len;
+    int kind;
+};
+
+// The huge page mappings of one solver context. alloc returns NULL for
+// buffers smaller than a huge page, when mode is off, or when mmap fails;
+// the caller keeps its heap buffer then. release unmaps everything handed
+// out, so it must outlast the variant state using the memory.
+class hugepage_arena {
+public:
+    uint32_t mode;  // CUCKOO_HUGEPAGES_* for the next alloc
+
+    hugepage_arena() : mode(CUCKOO_HUGEPAGES_OFF) {}
+    ~hugepage_arena() { release(); }
+
+    // Whether alloc would try to map n bytes rather than return NULL at once
+    bool takes(size_t n) const;
+    void* alloc(size_t n);
+    // Whether p was handed out by alloc
+    bool owns(const void* p);
+    void release();
+    // Sum up the huge page usage of the buffers handed out
+    void usage(cuckoo_hugepage_info* info);
+
+private:
+    std::mutex mu;  // guards regions against usage from another thread
+    std::vector<hugepage_region> regions;
+};
+
+// Allocator hook for Tromp's malloc'd and calloc'd buffers.
+// cuckoo_lean.cpp points his malloc, calloc and free at tromp_malloc,
+// tromp_calloc and tromp_free. While a hugepage_scope is open on the
+// thread, the first two take large requests from its arena, whose memory
+// mmap has already zeroed, and tromp_free leaves the arena's buffers to it.
+// Everything else, and everything outside a scope, goes to the C heap.
+class hugepage_scope {
+public:
+    explicit hugepage_scope(hugepage_arena* arena);
+    ~hugepage_scope();
+
+private:
+    hugepage_arena* prev;
+};
+
+void* tromp_malloc(size_t n);
+void* tromp_calloc(size_t n, size_t size);
+void tromp_free(void* p);
+
+// Replace a buffer of count Ts, which Tromp's constructor allocated with
+// new T[count], with arena memory. new[] has no hook short of replacing the
+// global operator new, so this runs right after his constructor. The heap
+// buffer is freed before the arena maps its own, so the two never exist at
+// once, and the contents are not kept. If the mapping fails, a new heap
+// buffer takes its place; buf is NULL if that fails too. Returns whether
+// buf is now arena memory, which the caller must clear before Tromp's
+// destructor delete[]s it.
+template <typename T>
+static inline bool hugepage_replace(hugepage_arena* arena, T*& buf, size_t count) {
+    // Neither delete[] here nor the arena's munmap runs destructors
+    static_assert(std::is_trivially_destructible<T>::value, "hugepage_replace needs a trivially destructible T");
+    if (!arena->takes(count * sizeof(T))) return false;
+    delete[] buf;
+    buf = (T*)arena->alloc(count * sizeof(T));
+    if (buf) return true;
+    buf = new (std::nothrow) T[count];
+    return false;
+}
+
+// Entry points of one compiled solver variant. impl is the variant's own
+// state (Tromp's context plus thread slots), created for a thread count with
+// its large buffers in arena.
+struct cuckoo_variant {
+    uint32_t backend;
+    uint32_t edgebits;
+    uint32_t proofsize;
+    void* (*create)(uint32_t nthreads, hugepage_arena* arena);
+    void (*destroy)(void* impl);
+    void (*abort)(void* impl);
+    int (*solve)(solver_ctx* ctx, void* impl);
+    int (*verify)(const uint8_t* header, uint32_t header_len, uint32_t nonce, const uint32_t* proof);
+    uint64_t (*membytes)(uint32_t nthreads);
+};
+
+// Variant-suffixed symbol name, e.g. VARIANT_FN(lean_solve, 23, 42) ->
+// lean_solve_e23p42. The Makefile keeps only these symbols global in each
+// variant object.
+#define VARIANT_FN_(name, eb, ps) name##_e##eb##p##ps
+#define VARIANT_FN(name, eb, ps) VARIANT_FN_(name, eb, ps)
+
+#endif // CUCKOO_INTERNAL_H
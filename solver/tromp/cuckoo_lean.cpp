@@ -1,211 +1,199 @@
 // Cuckoo Cycle lean solver wrapper
 // Adapts John Tromp's implementation for Go integration
+//
+// This file is compiled once per graph size listed in cuckoo_variants.h, with
+// EDGEBITS and PROOFSIZE set by the Makefile. Every entry point carries the
+// _e<EDGEBITS>p<PROOFSIZE> suffix; cuckoo_api.cpp dispatches between them.
 
-#include "cuckoo_lean.h"
+#if !defined(EDGEBITS) || !defined(PROOFSIZE)
+#error "EDGEBITS and PROOFSIZE must be set per variant (see Makefile)"
+#endif
+
+#include "cuckoo_internal.h"
 #include <string.h>
 #include <stdlib.h>
 #include <pthread.h>
 #include <stdio.h>
+#include <assert.h>
+#include <bitset>
+#include <memory>
+#include <new>
 
-// Include Tromp's implementation with our parameters
+// Include Tromp's implementation with our parameters. His bitmaps are
+// malloc'd and calloc'd, so those calls go through the arena's allocator
+// hook (see hugepage_scope). The standard headers he uses are included
+// above, so the macros only reach his code.
 #define HEADERLEN 80
+#define malloc(n) tromp_malloc(n)
+#define calloc(n, size) tromp_calloc(n, size)
+#define free(p) tromp_free(p)
 #include "cuckoo-orig/src/cuckoo/lean.hpp"
+#undef malloc
+#undef calloc
+#undef free
 #include "cuckoo-orig/src/crypto/blake2b-ref.c"
+#include "cuckoo_verify.h"
+
+static_assert(PROOFSIZE <= CUCKOO_MAX_PROOFSIZE, "PROOFSIZE exceeds proof_t capacity");
+
+#define LEAN_FN(name) VARIANT_FN(lean_##name, EDGEBITS, PROOFSIZE)
 
 // Forward declaration from lean.hpp
 void *worker(void *vp);
 
-// Wrapper context that includes Tromp's context
-struct internal_ctx {
+// Tromp's context plus thread slots for one thread count
+struct lean_state {
     cuckoo_ctx* tromp_ctx;
     thread_ctx* threads;
-    solver_ctx* api_ctx;
+    uint32_t nthreads;
+    hugepage_arena* arena;  // holds tromp_ctx's large bitmaps
 };
 
-// Global storage for internal contexts (removed - not needed)
+// Tromp's destructors free through the hook too, which leaves the arena's
+// buffers alone only within its scope
+static void delete_tromp_ctx(cuckoo_ctx* tctx, hugepage_arena* arena) {
+    hugepage_scope scope(arena);
+    delete tctx;
+}
 
 extern "C" {
 
-void cuckoo_init(solver_ctx* ctx) {
-    memset(ctx, 0, sizeof(solver_ctx));
-    ctx->nthreads = 1;
-    ctx->nonce_range = 1;
-    ctx->abort_flag = 0;
-    ctx->internal = NULL;
-}
+void* LEAN_FN(create)(uint32_t nthreads, hugepage_arena* arena) {
+    CUCKOO_LOG(CUCKOO_LOG_DEBUG, "cuckoo_solve: calculating ntrims");
+    // Initialize Tromp's context
+    int ntrims = 2 + (PART_BITS+3)*(PART_BITS+4);
+
+    CUCKOO_LOG(CUCKOO_LOG_DEBUG, "cuckoo_solve: creating cuckoo_ctx with edgebits=%d, nthreads=%u, ntrims=%d",
+            EDGEBITS, nthreads, ntrims);
 
-void cuckoo_setheader(solver_ctx* ctx, const uint8_t* header, uint32_t len) {
-    if (len > 80) len = 80;
-    memcpy(ctx->header, header, len);
-    ctx->header_len = len;
+    cuckoo_ctx* tctx;
+    try {
+        // The alive bitmap and nonleaf counters are the big ones (see
+        // membytes); the hook maps them from the arena
+        hugepage_scope scope(arena);
+        tctx = new cuckoo_ctx(nthreads, ntrims, MAXSOLS);
+        CUCKOO_LOG(CUCKOO_LOG_DEBUG, "cuckoo_solve: cuckoo_ctx created successfully");
+    } catch (std::bad_alloc& e) {
+        CUCKOO_LOG(CUCKOO_LOG_ERROR, "cuckoo_solve: failed to allocate cuckoo_ctx: %s", e.what());
+        return NULL;
+    } catch (...) {
+        CUCKOO_LOG(CUCKOO_LOG_ERROR, "cuckoo_solve: unknown error creating cuckoo_ctx");
+        return NULL;
+    }
+
+    CUCKOO_LOG(CUCKOO_LOG_DEBUG, "cuckoo_solve: allocating thread contexts");
+    // Allocate thread contexts
+    lean_state* st = new (std::nothrow) lean_state;
+    thread_ctx* threads = new (std::nothrow) thread_ctx[nthreads];
+    if (!st || !threads) {
+        delete st;
+        delete[] threads;
+        delete_tromp_ctx(tctx, arena);
+        return NULL;
+    }
+    st->tromp_ctx = tctx;
+    st->threads = threads;
+    st->nthreads = nthreads;
+    st->arena = arena;
+    return st;
 }
 
-void cuckoo_sethdrkey(solver_ctx* ctx, const uint8_t* key32) {
-    // Store key in the beginning of header buffer for reuse
-    memcpy(ctx->header, key32, 32);
-    ctx->header_len = 32; // denotes key length for our use
+void LEAN_FN(destroy)(void* impl) {
+    lean_state* st = (lean_state*)impl;
+    if (!st) return;
+    delete[] st->threads;
+    delete_tromp_ctx(st->tromp_ctx, st->arena);
+    delete st;
 }
 
-int cuckoo_solve(solver_ctx* ctx) {
-    fprintf(stderr, "[DEBUG] cuckoo_solve: starting, nthreads=%u, nonce=%u, range=%u\n", 
-            ctx->nthreads, ctx->nonce, ctx->nonce_range);
-    
-    // Create internal context
-    internal_ctx* ictx = new internal_ctx;
-    ictx->api_ctx = ctx;
-    ctx->internal = (void*)ictx;
-    ctx->abort_flag = 0;
-    
-    fprintf(stderr, "[DEBUG] cuckoo_solve: calculating ntrims\n");
-    // Initialize Tromp's context
-    int ntrims = 2 + (PART_BITS+3)*(PART_BITS+4);
-    
-    fprintf(stderr, "[DEBUG] cuckoo_solve: creating cuckoo_ctx with nthreads=%u, ntrims=%d\n", 
-            ctx->nthreads, ntrims);
-    
+void LEAN_FN(abort)(void* impl) {
+    lean_state* st = (lean_state*)impl;
+    // Try to abort running round by aborting barrier
     try {
-        ictx->tromp_ctx = new cuckoo_ctx(ctx->nthreads, ntrims, MAXSOLS);
-        fprintf(stderr, "[DEBUG] cuckoo_solve: cuckoo_ctx created successfully\n");
-    } catch (std::bad_alloc& e) {
-        fprintf(stderr, "[ERROR] cuckoo_solve: failed to allocate cuckoo_ctx: %s\n", e.what());
-        delete ictx;
-        ctx->internal = NULL;
-        return 0;
+        st->tromp_ctx->abort();
     } catch (...) {
-        fprintf(stderr, "[ERROR] cuckoo_solve: unknown error creating cuckoo_ctx\n");
-        delete ictx;
-        ctx->internal = NULL;
-        return 0;
+        // ignore
     }
-    
-    fprintf(stderr, "[DEBUG] cuckoo_solve: allocating thread contexts\n");
-    // Allocate thread contexts
-    ictx->threads = new thread_ctx[ctx->nthreads];
-    
-    ctx->solutions = 0;
-    
+}
+
+int LEAN_FN(solve)(solver_ctx* ctx, void* impl) {
+    lean_state* st = (lean_state*)impl;
+    cuckoo_ctx* tctx = st->tromp_ctx;
+
     // Search through nonce range
     for (uint32_t r = 0; r < ctx->nonce_range && ctx->solutions < MAXSOLS; r++) {
-        if (ctx->abort_flag) {
+        if (abort_requested(ctx)) {
             break;
         }
+        uint64_t setup_start = cuckoo_now_ns();
         // Initialize siphash keys directly from provided key32
         // Using first 32 bytes of ctx->header as key buffer
-        ictx->tromp_ctx->sip_keys.setkeys((const char*)ctx->header);
+        tctx->sip_keys.setkeys((const char*)ctx->header);
         // Reset state for this round
-        ictx->tromp_ctx->alive->clear();
-        ictx->tromp_ctx->nsols = 0;
-        ictx->tromp_ctx->nonce = ctx->nonce + r;
-        ictx->tromp_ctx->barry.clear();
-        
-        fprintf(stderr, "[DEBUG] cuckoo_solve: launching %u threads for nonce %u\n", ctx->nthreads, ctx->nonce + r);
+        tctx->alive->clear();
+        tctx->nsols = 0;
+        tctx->nonce = ctx->nonce + r;
+        tctx->barry.clear();
+        uint64_t solve_start = cuckoo_now_ns();
+        ctx->phase_ns[CUCKOO_PHASE_SETUP] += solve_start - setup_start;
+        // An abort that landed between the check above and clear() was
+        // wiped from the barrier; the flag still has it
+        if (abort_requested(ctx)) {
+            break;
+        }
+
+        CUCKOO_LOG(CUCKOO_LOG_DEBUG, "cuckoo_solve: launching %u threads for nonce %u", st->nthreads, ctx->nonce + r);
         // Launch threads
-        for (uint32_t t = 0; t < ctx->nthreads; t++) {
-            ictx->threads[t].id = t;
-            ictx->threads[t].ctx = ictx->tromp_ctx;
-            fprintf(stderr, "[DEBUG] cuckoo_solve: creating thread %u\n", t);
-            int err = pthread_create(&ictx->threads[t].thread, NULL, worker, (void*)&ictx->threads[t]);
+        uint32_t started = 0;
+        for (uint32_t t = 0; t < st->nthreads; t++) {
+            st->threads[t].id = t;
+            st->threads[t].ctx = tctx;
+            int err = pthread_create(&st->threads[t].thread, NULL, worker, (void*)&st->threads[t]);
             if (err) {
-                fprintf(stderr, "[ERROR] cuckoo_solve: failed to create thread %u: %d\n", t, err);
-                // Handle thread creation error
-                delete[] ictx->threads;
-                delete ictx->tromp_ctx;
-                delete ictx;
-                ctx->internal = NULL;
-                return 0;
+                CUCKOO_LOG(CUCKOO_LOG_ERROR, "cuckoo_solve: failed to create thread %u: %d", t, err);
+                break;
             }
+            started++;
         }
-        
+        if (started < st->nthreads) {
+            // Release the threads that did start before giving up
+            tctx->abort();
+            for (uint32_t t = 0; t < started; t++) {
+                pthread_join(st->threads[t].thread, NULL);
+            }
+            break;
+        }
+
         // Wait for threads
-        for (uint32_t t = 0; t < ctx->nthreads; t++) {
-            pthread_join(ictx->threads[t].thread, NULL);
+        for (uint32_t t = 0; t < st->nthreads; t++) {
+            pthread_join(st->threads[t].thread, NULL);
+        }
+        ctx->phase_ns[CUCKOO_PHASE_SOLVE] += cuckoo_now_ns() - solve_start;
+        // Threads cut short by an abort leave the graph half trimmed
+        if (!abort_requested(ctx)) {
+            ctx->graphs++;
         }
-        
+
         // Copy solutions
-        for (unsigned s = 0; s < ictx->tromp_ctx->nsols && ctx->solutions < MAXSOLS; s++) {
+        for (unsigned s = 0; s < tctx->nsols && ctx->solutions < MAXSOLS; s++) {
             for (int i = 0; i < PROOFSIZE; i++) {
-                ctx->proofs[ctx->solutions].nonce[i] = ictx->tromp_ctx->sols[s][i];
+                ctx->proofs[ctx->solutions].nonce[i] = tctx->sols[s][i];
             }
             ctx->solutions++;
         }
     }
-    
-    // Cleanup
-    delete[] ictx->threads;
-    delete ictx->tromp_ctx;
-    delete ictx;
-    ctx->internal = NULL;
-    
-    return ctx->solutions;
-}
 
-void cuckoo_abort(solver_ctx* ctx) {
-    if (!ctx) return;
-    ctx->abort_flag = 1;
-    // Try to abort running round by aborting barrier
-    internal_ctx* ictx = (internal_ctx*)ctx->internal;
-    if (ictx && ictx->tromp_ctx) {
-        try {
-            ictx->tromp_ctx->abort();
-        } catch (...) {
-            // ignore
-        }
-    }
+    return ctx->solutions;
 }
 
-int cuckoo_verify(const uint8_t* header, uint32_t header_len, uint32_t nonce, const uint32_t* proof) {
-    siphash_keys keys;
-    char headernonce[88];
-    
-    // Prepare header with nonce
-    memcpy(headernonce, header, header_len);
-    memcpy(headernonce + header_len, &nonce, sizeof(nonce));
-    
-    // Generate siphash keys
-    setheader(headernonce, header_len + sizeof(nonce), &keys);
-    
-    // Verify the proof
-    u32 uvs[2*PROOFSIZE];
-    u32 xor0 = 0, xor1 = 0;
-    
-    for (u32 n = 0; n < PROOFSIZE; n++) {
-        if (n > 0 && proof[n] <= proof[n-1])
-            return 0;
-        u32 node0 = sipnode(&keys, proof[n], 0);
-        u32 node1 = sipnode(&keys, proof[n], 1);
-        uvs[2*n] = node0;
-        uvs[2*n+1] = node1;
-        xor0 ^= node0;
-        xor1 ^= node1;
-    }
-    
-    if (xor0 | xor1)
-        return 0;
-    
-    // Check cycle
-    u32 n = 0, i = 0;
-    do {
-        u32 j = i;
-        for (u32 k = 0; k < 2*PROOFSIZE; k += 2) {
-            if (k != i && uvs[k] == uvs[i]) {
-                if (j != i)
-                    return 0;
-                j = k;
-            }
-        }
-        if (j == i)
-            return 0;
-        i = j^1;
-        n++;
-    } while (i != 0);
-    
-    return n == PROOFSIZE;
+int LEAN_FN(verify)(const uint8_t* header, uint32_t header_len, uint32_t nonce, const uint32_t* proof) {
+    return verify_proof(header, header_len, nonce, proof);
 }
 
-void cuckoo_sha256d(const uint8_t* data, size_t len, uint8_t* hash) {
-    // Use blake2b for now, should be replaced with actual SHA256d
-    blake2b(hash, 32, data, len, NULL, 0);
-    blake2b(hash, 32, hash, 32, NULL, 0);
+uint64_t LEAN_FN(membytes)(uint32_t nthreads) {
+    // alive bitmap (one bit per edge) plus two-bit nonleaf counters per node
+    (void)nthreads;
+    return (uint64_t)NEDGES / 8 * 3;
 }
 
 } // extern "C"
@@ -11,13 +11,31 @@ extern "C" {
 #include <stdint.h>
 #include <stddef.h>
 
-// Parameters matching Java miner
-#define EDGEBITS 23
-#define PROOFSIZE 42
+// Default parameters matching Java miner. Other graph sizes are selected per
+// context through solver_ctx.edgebits/proofsize (see cuckoo_variants.h).
+#define CUCKOO_DEFAULT_EDGEBITS 23
+#define CUCKOO_DEFAULT_PROOFSIZE 42
+
+// Solver backends
+#define CUCKOO_BACKEND_LEAN 0  // Tromp's lean solver: small memory, one graph per thread
+#define CUCKOO_BACKEND_MEAN 1  // Tromp's mean solver: bucketed, memory-hard, fast on many cores
+
+// How solver_ctx.hugepages backs the solvers' large (>= 2 MiB) buffers
+#define CUCKOO_HUGEPAGES_OFF     0  // plain new/malloc
+#define CUCKOO_HUGEPAGES_THP     1  // 2 MiB aligned mmap with madvise(MADV_HUGEPAGE)
+#define CUCKOO_HUGEPAGES_HUGETLB 2  // mmap(MAP_HUGETLB) from vm.nr_hugepages, else as THP
+
+// Upper bound on PROOFSIZE across all built variants
+#define CUCKOO_MAX_PROOFSIZE 64
 #define MAXSOLS 8
 
+// Phases of a solve timed in solver_ctx.phase_ns
+#define CUCKOO_PHASE_SETUP 0  // keying and clearing the graph for each nonce
+#define CUCKOO_PHASE_SOLVE 1  // Tromp's trimming rounds and cycle search
+#define CUCKOO_NPHASES 2
+
 typedef struct {
-    uint32_t nonce[PROOFSIZE];
+    uint32_t nonce[CUCKOO_MAX_PROOFSIZE]; // first proofsize entries are used
 } proof_t;
 
 typedef struct {
@@ -26,13 +44,49 @@ typedef struct {
     uint32_t nonce;        // Base nonce
     uint32_t nonce_range;  // Nonce range to search
     uint32_t nthreads;     // Number of threads
+    uint32_t backend;      // CUCKOO_BACKEND_*
+    uint32_t hugepages;    // CUCKOO_HUGEPAGES_*; read when solver memory is allocated
+    uint32_t edgebits;     // Graph size (log2 of edge count)
+    uint32_t proofsize;    // Cycle length
     uint32_t solutions;    // Number of solutions found
+    uint32_t graphs;       // Graphs fully searched by the last cuckoo_solve (aborted ones don't count)
     proof_t proofs[MAXSOLS]; // Found solutions
+    uint64_t phase_ns[CUCKOO_NPHASES]; // Time per CUCKOO_PHASE_* in the last cuckoo_solve
     // Abort/cancellation support
-    uint32_t abort_flag;   // non-zero to request abort
+    uint32_t abort_flag;   // non-zero to request abort; atomic access only
     void* internal;        // internal context pointer for control
 } solver_ctx;
 
+// Diagnostic log levels
+#define CUCKOO_LOG_DEBUG 0
+#define CUCKOO_LOG_INFO  1
+#define CUCKOO_LOG_WARN  2
+#define CUCKOO_LOG_ERROR 3
+#define CUCKOO_LOG_NONE  4
+
+// Sink for solver diagnostics. msg is only valid for the duration of the call.
+typedef void (*cuckoo_log_fn)(int level, const char* msg);
+
+// Route diagnostics to fn. Messages below min_level are dropped before they
+// are formatted. The solver is silent until a logger is installed; pass NULL
+// (or CUCKOO_LOG_NONE) to silence it again.
+void cuckoo_set_logger(cuckoo_log_fn fn, int min_level);
+
+// Allocate and initialize a solver context. The context owns all solver
+// memory until it is released with cuckoo_free. Returns NULL on failure.
+solver_ctx* cuckoo_alloc(void);
+
+// Release a context and any solver state attached to it. Must not be called
+// while cuckoo_solve is running on the same context.
+void cuckoo_free(solver_ctx* ctx);
+
+// Number of contexts allocated with cuckoo_alloc and not yet freed
+int cuckoo_live_contexts(void);
+
+// Solver states (the memory-heavy part, created on a context's first solve)
+// created and destroyed so far
+void cuckoo_impl_counts(uint64_t* created, uint64_t* destroyed);
+
 // Initialize solver context
 void cuckoo_init(solver_ctx* ctx);
 
@@ -42,14 +96,45 @@ void cuckoo_setheader(solver_ctx* ctx, const uint8_t* header, uint32_t len);
 // Set 32-byte header-derived key (SHA256d(header)) for siphash
 void cuckoo_sethdrkey(solver_ctx* ctx, const uint8_t* key32);
 
-// Find cycles in nonce range
+// Report whether the library was built for the given backend and graph
+int cuckoo_supported(uint32_t backend, uint32_t edgebits, uint32_t proofsize);
+
+// Number of built variants and the backend/graph parameters of variant i
+int cuckoo_variant_count(void);
+int cuckoo_variant_params(int i, uint32_t* backend, uint32_t* edgebits, uint32_t* proofsize);
+
+// Bytes of solver memory a context would allocate for the given backend,
+// graph and thread count, or 0 if that variant is not built
+uint64_t cuckoo_membytes(uint32_t backend, uint32_t edgebits, uint32_t proofsize, uint32_t nthreads);
+
+// Huge page usage of a context's solver memory
+typedef struct {
+    uint64_t large_bytes;   // buffers allocated under ctx->hugepages (0 when off)
+    uint64_t hugetlb_bytes; // of which backed by MAP_HUGETLB pages
+    uint64_t thp_bytes;     // of which backed by transparent huge pages right now
+} cuckoo_hugepage_info;
+
+// Report how much of ctx's solver memory is on huge pages. The memory is
+// allocated by the first cuckoo_solve, so this is all zero before that. Safe
+// to call while cuckoo_solve runs.
+void cuckoo_hugepages(solver_ctx* ctx, cuckoo_hugepage_info* info);
+
+// Find cycles in nonce range using ctx->backend/edgebits/proofsize. Returns 0
+// when that combination is not supported by this build.
 int cuckoo_solve(solver_ctx* ctx);
 
-// Request abort of an in-flight solve
+// Request abort of an in-flight solve. Safe to call from any thread while
+// cuckoo_solve runs. The request sticks until cuckoo_reset_abort, so an abort
+// issued just before cuckoo_solve starts is not lost.
 void cuckoo_abort(solver_ctx* ctx);
 
-// Verify a solution
-int cuckoo_verify(const uint8_t* header, uint32_t header_len, uint32_t nonce, const uint32_t* proof);
+// Clear a pending abort request before starting a new solve
+void cuckoo_reset_abort(solver_ctx* ctx);
+
+// Verify a proofsize-long solution on an edgebits-sized graph (any backend
+// built for that graph can check it)
+int cuckoo_verify(uint32_t edgebits, uint32_t proofsize,
+                  const uint8_t* header, uint32_t header_len, uint32_t nonce, const uint32_t* proof);
 
 // Hash function for target checking (SHA256d)
 void cuckoo_sha256d(const uint8_t* data, size_t len, uint8_t* hash);
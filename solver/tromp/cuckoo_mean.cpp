@@ -0,0 +1,165 @@
+// Cuckoo Cycle mean solver wrapper
+// Adapts John Tromp's memory-hard bucketed ("mean") solver for Go integration
+//
+// Like cuckoo_lean.cpp this file is compiled once per graph size listed in
+// cuckoo_variants.h and exports _e<EDGEBITS>p<PROOFSIZE>-suffixed entry
+// points. The mean solver trims with its own thread pool, so one solve call
+// uses all nthreads on a single graph.
+
+#if !defined(EDGEBITS) || !defined(PROOFSIZE)
+#error "EDGEBITS and PROOFSIZE must be set per variant (see Makefile)"
+#endif
+
+#include "cuckoo_internal.h"
+#include <string.h>
+#include <stdlib.h>
+#include <stdio.h>
+#include <new>
+
+// Tromp's mean.hpp has its own class named solver_ctx; keep it out of the way
+// of the C API struct of the same name
+#define solver_ctx tromp_solver_ctx
+#define HEADERLEN 80
+#include "cuckoo-orig/src/cuckoo/mean.hpp"
+#include "cuckoo-orig/src/crypto/blake2b-ref.c"
+#undef solver_ctx
+#include "cuckoo_verify.h"
+
+static_assert(PROOFSIZE <= CUCKOO_MAX_PROOFSIZE, "PROOFSIZE exceeds proof_t capacity");
+
+// hugepage_replace relies on the trimmer's buckets being arrays Tromp's
+// constructor allocates with new[] and his destructor delete[]s. A change
+// to their types means his allocation changed too.
+static_assert(std::is_same<decltype(edgetrimmer::buckets), yzbucket<ZBUCKETSIZE>*>::value,
+              "trimmer.buckets is no longer a new[]'d yzbucket array; revisit hugepage_replace");
+static_assert(std::is_same<decltype(edgetrimmer::tbuckets), yzbucket<TBUCKETSIZE>*>::value,
+              "trimmer.tbuckets is no longer a new[]'d yzbucket array; revisit hugepage_replace");
+
+#define MEAN_FN(name) VARIANT_FN(mean_##name, EDGEBITS, PROOFSIZE)
+
+// Tromp's mean solver; it owns its trimming threads
+struct mean_state {
+    tromp_solver_ctx* tromp_ctx;
+    uint32_t nthreads;
+    bool buckets_moved;   // trimmer.buckets lives in the context's hugepage_arena
+    bool tbuckets_moved;  // so does trimmer.tbuckets
+};
+
+// Hand back the arena's buffers before Tromp's destructors free theirs
+static void delete_tromp_ctx(tromp_solver_ctx* tctx, bool buckets_moved, bool tbuckets_moved) {
+    if (buckets_moved) tctx->trimmer.buckets = NULL;
+    if (tbuckets_moved) tctx->trimmer.tbuckets = NULL;
+    delete tctx;
+}
+
+extern "C" {
+
+void* MEAN_FN(create)(uint32_t nthreads, hugepage_arena* arena) {
+    // Same trimming round count as Tromp's mean miner default
+    int ntrims = EDGEBITS >= 30 ? 96 : 68;
+
+    CUCKOO_LOG(CUCKOO_LOG_DEBUG, "cuckoo_solve: creating mean solver with edgebits=%d, nthreads=%u, ntrims=%d",
+            EDGEBITS, nthreads, ntrims);
+
+    tromp_solver_ctx* tctx;
+    try {
+        tctx = new tromp_solver_ctx(nthreads, ntrims, false, false, false);
+    } catch (std::bad_alloc& e) {
+        CUCKOO_LOG(CUCKOO_LOG_ERROR, "cuckoo_solve: failed to allocate mean solver: %s", e.what());
+        return NULL;
+    } catch (...) {
+        CUCKOO_LOG(CUCKOO_LOG_ERROR, "cuckoo_solve: unknown error creating mean solver");
+        return NULL;
+    }
+
+    // The shared bucket matrix and per-thread buckets are the big ones
+    // (see membytes)
+    bool buckets_moved = hugepage_replace(arena, tctx->trimmer.buckets,
+                                          sizeof(matrix<ZBUCKETSIZE>) / sizeof(yzbucket<ZBUCKETSIZE>));
+    bool tbuckets_moved = hugepage_replace(arena, tctx->trimmer.tbuckets, nthreads);
+
+    mean_state* st = new (std::nothrow) mean_state;
+    if (!st || !tctx->trimmer.buckets || !tctx->trimmer.tbuckets) {
+        delete st;
+        delete_tromp_ctx(tctx, buckets_moved, tbuckets_moved);
+        return NULL;
+    }
+    st->tromp_ctx = tctx;
+    st->nthreads = nthreads;
+    st->buckets_moved = buckets_moved;
+    st->tbuckets_moved = tbuckets_moved;
+    return st;
+}
+
+void MEAN_FN(destroy)(void* impl) {
+    mean_state* st = (mean_state*)impl;
+    if (!st) return;
+    delete_tromp_ctx(st->tromp_ctx, st->buckets_moved, st->tbuckets_moved);
+    delete st;
+}
+
+void MEAN_FN(abort)(void* impl) {
+    mean_state* st = (mean_state*)impl;
+    try {
+        st->tromp_ctx->abort();
+    } catch (...) {
+        // ignore
+    }
+}
+
+int MEAN_FN(solve)(solver_ctx* ctx, void* impl) {
+    mean_state* st = (mean_state*)impl;
+    tromp_solver_ctx* tctx = st->tromp_ctx;
+
+    // Search through nonce range
+    for (uint32_t r = 0; r < ctx->nonce_range && ctx->solutions < MAXSOLS; r++) {
+        if (abort_requested(ctx)) {
+            break;
+        }
+        uint64_t setup_start = cuckoo_now_ns();
+        // Siphash keys come straight from the 32-byte key in ctx->header,
+        // exactly as for the lean solver
+        tctx->trimmer.sip_keys.setkeys((const char*)ctx->header);
+        tctx->sols.clear();
+        // Re-arm the trimmer after a previous abort
+        tctx->trimmer.aborted = false;
+        tctx->trimmer.barry.clear();
+        uint64_t solve_start = cuckoo_now_ns();
+        ctx->phase_ns[CUCKOO_PHASE_SETUP] += solve_start - setup_start;
+        if (abort_requested(ctx)) {
+            break;
+        }
+
+        CUCKOO_LOG(CUCKOO_LOG_DEBUG, "cuckoo_solve: mean round with %u threads for nonce %u", st->nthreads, ctx->nonce + r);
+        int nsols = tctx->solve();
+        ctx->phase_ns[CUCKOO_PHASE_SOLVE] += cuckoo_now_ns() - solve_start;
+        // An aborted trim leaves the graph half searched
+        if (!abort_requested(ctx)) {
+            ctx->graphs++;
+        }
+
+        // Copy solutions; sols holds PROOFSIZE edges per cycle back to back
+        for (int s = 0; s < nsols && ctx->solutions < MAXSOLS; s++) {
+            for (int i = 0; i < PROOFSIZE; i++) {
+                ctx->proofs[ctx->solutions].nonce[i] = tctx->sols[s * PROOFSIZE + i];
+            }
+            ctx->solutions++;
+        }
+    }
+
+    return ctx->solutions;
+}
+
+int MEAN_FN(verify)(const uint8_t* header, uint32_t header_len, uint32_t nonce, const uint32_t* proof) {
+    return verify_proof(header, header_len, nonce, proof);
+}
+
+uint64_t MEAN_FN(membytes)(uint32_t nthreads) {
+    // Mirrors tromp_solver_ctx::sharedbytes() + nthreads * threadbytes(),
+    // computed from the types so it is known before allocating
+    uint64_t shared = sizeof(matrix<ZBUCKETSIZE>);
+    uint64_t perthread = sizeof(yzbucket<TBUCKETSIZE>) + sizeof(zbucket8) + sizeof(zbucket16) + sizeof(zbucket32);
+    return shared + (uint64_t)nthreads * perthread;
+}
+
+} // extern "C"
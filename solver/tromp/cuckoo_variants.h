@@ -0,0 +1,22 @@
+// Graph sizes compiled into libcuckoo_lean.a
+//
+// Each X(EDGEBITS, PROOFSIZE) entry is one copy of a solver built by the
+// Makefile (see LEAN_VARIANTS/MEAN_VARIANTS there; the lists must match).
+
+#ifndef CUCKOO_VARIANTS_H
+#define CUCKOO_VARIANTS_H
+
+#define CUCKOO_LEAN_VARIANTS(X) \
+    X(19, 42) \
+    X(23, 42) \
+    X(29, 42) \
+    X(31, 42)
+
+// The mean solver needs roughly 2^EDGEBITS * 12 bytes, so the largest graph
+// is left to lean
+#define CUCKOO_MEAN_VARIANTS(X) \
+    X(19, 42) \
+    X(23, 42) \
+    X(29, 42)
+
+#endif // CUCKOO_VARIANTS_H
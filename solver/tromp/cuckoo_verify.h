@@ -0,0 +1,58 @@
+// Solution check shared by the solver variants.
+//
+// Include after one of Tromp's solver headers: it relies on EDGEBITS,
+// PROOFSIZE, setheader and sipnode from there.
+
+#ifndef CUCKOO_VERIFY_H
+#define CUCKOO_VERIFY_H
+
+static int verify_proof(const uint8_t* header, uint32_t header_len, uint32_t nonce, const uint32_t* proof) {
+    siphash_keys keys;
+    char headernonce[88];
+
+    // Prepare header with nonce
+    memcpy(headernonce, header, header_len);
+    memcpy(headernonce + header_len, &nonce, sizeof(nonce));
+
+    // Generate siphash keys
+    setheader(headernonce, header_len + sizeof(nonce), &keys);
+
+    // Verify the proof
+    u32 uvs[2*PROOFSIZE];
+    u32 xor0 = 0, xor1 = 0;
+
+    for (u32 n = 0; n < PROOFSIZE; n++) {
+        if (n > 0 && proof[n] <= proof[n-1])
+            return 0;
+        u32 node0 = sipnode(&keys, proof[n], 0);
+        u32 node1 = sipnode(&keys, proof[n], 1);
+        uvs[2*n] = node0;
+        uvs[2*n+1] = node1;
+        xor0 ^= node0;
+        xor1 ^= node1;
+    }
+
+    if (xor0 | xor1)
+        return 0;
+
+    // Check cycle
+    u32 n = 0, i = 0;
+    do {
+        u32 j = i;
+        for (u32 k = 0; k < 2*PROOFSIZE; k += 2) {
+            if (k != i && uvs[k] == uvs[i]) {
+                if (j != i)
+                    return 0;
+                j = k;
+            }
+        }
+        if (j == i)
+            return 0;
+        i = j^1;
+        n++;
+    } while (i != 0);
+
+    return n == PROOFSIZE;
+}
+
+#endif // CUCKOO_VERIFY_H
@@ -36,9 +36,9 @@ int main() {
     printf("✓ cuckoo_solve returned: %d solutions\n", solutions);
     
     // Test verify (even if no solution)
-    uint32_t proof[42] = {0};
+    uint32_t proof[CUCKOO_DEFAULT_PROOFSIZE] = {0};
     printf("Testing cuckoo_verify...\n");
-    int valid = cuckoo_verify(header, 80, 0, proof);
+    int valid = cuckoo_verify(ctx.edgebits, ctx.proofsize, header, 80, 0, proof);
     printf("✓ cuckoo_verify returned: %d\n", valid);
     
     printf("=== Test Complete ===\n");